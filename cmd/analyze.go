@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-clix/cli"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	analyze "github.com/xvzf/htw-crypto-project/pkg/crypt/analyze"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+	"github.com/xvzf/htw-crypto-project/pkg/lm"
+)
+
+// defaultAlphabet is used for writing substitution ciphers
+const defaultAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+func analyzeCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "analyze",
+		Short: "Analyze ciphertexts without knowing the key",
+	}
+
+	cmd.AddCommand(
+		analyzeGroupsCmd(),
+		analyzeLinkCmd(),
+		analyzeSolveCmd(),
+		analyzeHeatmapCmd(),
+		analyzeReportCmd(),
+		analyzeUniformityCmd(),
+	)
+	return cmd
+}
+
+func analyzeGroupsCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "groups <ciphertext>...",
+		Short: "Cluster pixel positions into homophone groups by their frequency",
+		Args:  argsMin(1),
+	}
+
+	clusterers := make([]string, 0, len(analyze.Clusterers))
+	for name := range analyze.Clusterers {
+		clusterers = append(clusterers, name)
+	}
+	sort.Strings(clusterers)
+
+	clusterer := cmd.Flags().StringP("clusterer", "c", "stdev", fmt.Sprintf("Comma separated clustering strategies (%s)", strings.Join(clusterers, ", ")))
+	groups := cmd.Flags().IntP("groups", "n", 26, "Number of homophone groups")
+	alphabet := cmd.Flags().String("alphabet", defaultAlphabet, "Symbols used for the substitution cipher")
+	substitute := cmd.Flags().StringP("substitute", "s", "", "Write the ciphertext as substitution cipher to this file (first clusterer only)")
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		// The ciphertexts are read twice for the substitution
+		if *substitute != "" {
+			var cleanup func()
+			var err error
+			if args, cleanup, err = spoolStdin(args); err != nil {
+				return err
+			}
+			defer cleanup()
+		}
+
+		a, err := analyze.LoadFiles(args, openReader, 0)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "CLUSTERER\tGROUPS\tMIN POSITIONS\tMAX POSITIONS\tWITHIN SS")
+
+		for i, name := range strings.Split(*clusterer, ",") {
+			c, err := analyze.NewClusterer(name)
+			if err != nil {
+				return err
+			}
+
+			g, err := a.ExtractGroupsWith(c, *groups)
+			if err != nil {
+				return err
+			}
+
+			min, max := len(a.Frequency), 0
+			for _, v := range g {
+				if len(v.PixelPositions) < min {
+					min = len(v.PixelPositions)
+				}
+				if len(v.PixelPositions) > max {
+					max = len(v.PixelPositions)
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.0f\n", name, len(g), min, max, a.WithinSumOfSquares(g))
+
+			if i == 0 && *substitute != "" {
+				if err := writeSubstitution(*substitute, args, g, *alphabet); err != nil {
+					return err
+				}
+			}
+		}
+
+		return tw.Flush()
+	}
+	return cmd
+}
+
+// writeSubstitution streams the ciphertexts frame by frame into a substitution cipher
+func writeSubstitution(name string, ciphertexts []string, groups []analyze.PixelGroupFrequency, alphabet string) error {
+	sub, err := analyze.NewSubstitution(groups, alphabet)
+	if err != nil {
+		return err
+	}
+
+	o, err := createOutput(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(o)
+
+	substitute := func(name string) error {
+		f, err := openInput(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r, err := crypt.NewReader(f)
+		if err != nil {
+			return err
+		}
+		r.SkipAuthentication()
+		for {
+			frame, err := r.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			s, err := sub.Apply(frame)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(s); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, c := range ciphertexts {
+		if err := substitute(c); err != nil {
+			return o.finish(fmt.Errorf("%s: %w", c, err))
+		}
+	}
+	return o.finish(w.Flush())
+}
+
+// readCiphertext reads all positions of a ciphertext file
+func readCiphertext(name string) ([]crypt.PixelPosition, error) {
+	_, enc, err := readCiphertextHeader(name)
+	return enc, err
+}
+
+func analyzeLinkCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "link <ciphertext>...",
+		Short: "Recover homophone groups from multiple encryptions of the same plaintext",
+		Args:  argsMin(2),
+	}
+
+	alphabet := cmd.Flags().String("alphabet", defaultAlphabet, "Symbols used for the substitution cipher")
+	substitute := cmd.Flags().StringP("substitute", "s", "", "Write the first ciphertext as substitution cipher to this file")
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		l := analyze.NewLinker()
+
+		// Stream all ciphertexts chunk-wise in lockstep
+		var readers []*crypt.Reader
+		for _, name := range args {
+			f, err := openInput(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			r, err := crypt.NewReader(f)
+			if err != nil {
+				return err
+			}
+			r.SkipAuthentication()
+			readers = append(readers, r)
+		}
+
+		aligned := newAlignedReader(readers)
+		for {
+			chunks, err := aligned.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			l.Link(chunks...)
+		}
+
+		groups := l.Groups()
+		fmt.Printf("Recovered %d homophone groups from %d ciphertexts\n", len(groups), len(args))
+		for i, g := range groups {
+			if i == 10 {
+				fmt.Printf("  ...\n")
+				break
+			}
+			fmt.Printf("  group %d: %d positions, %d occurrences\n", i, len(g.PixelPositions), g.Total)
+		}
+
+		if *substitute == "" {
+			return nil
+		}
+		if len(groups) > len(*alphabet) {
+			return fmt.Errorf("%d groups can not be represented by an alphabet of %d symbols", len(groups), len(*alphabet))
+		}
+
+		enc, err := readCiphertext(args[0])
+		if err != nil {
+			return err
+		}
+		s, err := analyze.Substitute(enc[:aligned.offset], groups, *alphabet)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(*substitute, []byte(s), 0644)
+	}
+	return cmd
+}
+
+// alignedReader reads chunks starting at the same offset from multiple ciphertexts
+type alignedReader struct {
+	readers []*crypt.Reader
+	buf     [][]crypt.PixelPosition
+	// offset of the next chunk
+	offset int
+}
+
+func newAlignedReader(readers []*crypt.Reader) *alignedReader {
+	return &alignedReader{
+		readers: readers,
+		buf:     make([][]crypt.PixelPosition, len(readers)),
+	}
+}
+
+// next returns the next aligned chunks, io.EOF is returned once a ciphertext is exhausted
+func (a *alignedReader) next() ([][]crypt.PixelPosition, error) {
+	// Refill empty buffers
+	for i, r := range a.readers {
+		for len(a.buf[i]) == 0 {
+			frame, err := r.Next()
+			if err != nil {
+				return nil, err
+			}
+			a.buf[i] = frame
+		}
+	}
+
+	n := len(a.buf[0])
+	for _, b := range a.buf {
+		if len(b) < n {
+			n = len(b)
+		}
+	}
+
+	out := make([][]crypt.PixelPosition, len(a.buf))
+	for i := range a.buf {
+		out[i] = a.buf[i][:n]
+		a.buf[i] = a.buf[i][n:]
+	}
+	a.offset += n
+
+	return out, nil
+}
+
+func analyzeSolveCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "solve <ciphertext> [<aligned ciphertext>]...",
+		Short: "Solve a ciphertext as homophonic substitution using a language model",
+		Args:  argsMin(1),
+	}
+
+	model := cmd.Flags().StringP("model", "m", "", "Language model trained using lm train")
+	corpus := cmd.Flags().String("corpus", "", "Train the language model on this text file instead")
+	normalize := cmd.Flags().StringP("normalize", "n", "words", fmt.Sprintf("Alphabet normalization of the corpus (%s)", normalizationNames()))
+	restarts := cmd.Flags().IntP("restarts", "r", 8, "Number of annealing runs")
+	iterations := cmd.Flags().IntP("iterations", "i", 2000, "Iterations per unit and run")
+	temperature := cmd.Flags().Float64("temperature", 0.25, "Initial annealing temperature")
+	seed := cmd.Flags().Int64("seed", 1, "Seed of the search")
+	out := cmd.Flags().StringP("output", "o", "-", "Write the plaintext to this file")
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		var m *lm.Model
+		var err error
+		switch {
+		case *model != "":
+			m, err = readModel(*model)
+		case *corpus != "":
+			m, err = trainModel(*corpus, *normalize, "")
+		default:
+			err = fmt.Errorf("a language model or corpus is required")
+		}
+		if err != nil {
+			return err
+		}
+
+		var in [][]crypt.PixelPosition
+		for _, name := range args {
+			enc, err := readCiphertext(name)
+			if err != nil {
+				return err
+			}
+			in = append(in, enc)
+		}
+
+		// Further encryptions of the same plaintext allow linking positions into groups
+		var groups []analyze.PixelGroupFrequency
+		if len(in) > 1 {
+			groups = analyze.LinkAlignedCiphertexts(in).Groups
+		}
+
+		s := analyze.NewSolver(m)
+		s.Restarts = *restarts
+		s.Iterations = *iterations
+		s.Temperature = *temperature
+		s.Seed = *seed
+
+		sol, err := s.SolveGroups(in[0], groups)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Score: %.4f per quadgram\n", sol.Score)
+
+		o, err := createOutput(*out)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(o, sol.Plaintext)
+		return o.finish(err)
+	}
+	return cmd
+}
+
+func analyzeHeatmapCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "heatmap <ciphertext>...",
+		Short: "Render the pixel position frequencies as PNG heatmap",
+		Args:  argsMin(1),
+	}
+
+	out := cmd.Flags().StringP("output", "o", "heatmap.png", "Write the heatmap to this file")
+	clusterer := cmd.Flags().StringP("clusterer", "c", "", "Color the homophone groups found by this clustering strategy")
+	groups := cmd.Flags().IntP("groups", "n", 26, "Number of homophone groups")
+	key := cmd.Flags().StringP("key", "k", "", "Draw this key image next to the heatmap")
+	scale := cmd.Flags().IntP("scale", "s", 1, "Edge length of a key pixel in the heatmap")
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		// The ciphertexts are read twice
+		args, cleanup, err := spoolStdin(args)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		header, err := commonHeader(args)
+		if err != nil {
+			return err
+		}
+		a, err := analyze.LoadFiles(args, openReader, 0)
+		if err != nil {
+			return err
+		}
+		opts := analyze.HeatmapOptions{Scale: *scale}
+
+		// The header describes the key, the dimension is inferred otherwise
+		ch := image.ChannelDefault
+		if header != nil {
+			opts.Dimension = header.Dimension
+			opts.Mask = header.Mask
+			ch = header.Channel
+		}
+
+		if *clusterer != "" {
+			c, err := analyze.NewClusterer(*clusterer)
+			if err != nil {
+				return err
+			}
+			if opts.Groups, err = a.ExtractGroupsWith(c, *groups); err != nil {
+				return err
+			}
+		}
+
+		if *key != "" {
+			k, err := readKey(*key, ch)
+			if err != nil {
+				return err
+			}
+			opts.Key = k
+			if opts.Dimension.Width == 0 {
+				opts.Dimension = k.Dimension
+			}
+		}
+
+		img, err := a.Heatmap(opts)
+		if err != nil {
+			return err
+		}
+		o, err := createOutput(*out)
+		if err != nil {
+			return err
+		}
+		return o.finish(png.Encode(o, img))
+	}
+	return cmd
+}
+
+// commonHeader returns the header shared by the binary ciphertexts, nil if all are JSON.
+// Ciphertexts encrypted using different keys are rejected.
+func commonHeader(names []string) (*crypt.Header, error) {
+	var out *crypt.Header
+	var first string
+	for _, name := range names {
+		h, err := readHeader(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if h == nil {
+			continue
+		}
+		if out == nil {
+			out, first = h, name
+			continue
+		}
+		if h.Dimension != out.Dimension || h.Mask != out.Mask || h.Channel != out.Channel ||
+			!bytes.Equal(h.Fingerprint, out.Fingerprint) {
+			return nil, fmt.Errorf("%s: ciphertext was encrypted using a different key than %s", name, first)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/go-clix/cli"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	analyze "github.com/xvzf/htw-crypto-project/pkg/crypt/analyze"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+func attackCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "attack",
+		Short: "Attacks against the cipher",
+	}
+
+	cmd.AddCommand(
+		attackKnownPlaintextCmd(),
+	)
+	return cmd
+}
+
+func attackKnownPlaintextCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "known-plaintext <plaintext> <ciphertext> [<plaintext> <ciphertext>]...",
+		Short: "Reconstruct the key image from known plaintext/ciphertext pairs",
+		Args:  argsMin(2),
+	}
+
+	out := cmd.Flags().StringP("output", "o", "recovered.png", "Write the partial key image to this file")
+	known := cmd.Flags().String("known", "", "Write the coverage mask of the recovered pixels to this file")
+	asJSON := cmd.Flags().Bool("json", false, "Output the report as JSON")
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		if len(args)%2 != 0 {
+			return fmt.Errorf("expected plaintext/ciphertext pairs, received %d arg(s)", len(args))
+		}
+
+		var pairs []analyze.Pair
+		for i := 0; i < len(args); i += 2 {
+			plain, err := ioutil.ReadFile(args[i])
+			if err != nil {
+				return err
+			}
+			h, enc, err := readCiphertextHeader(args[i+1])
+			if err != nil {
+				return err
+			}
+			pairs = append(pairs, analyze.Pair{Plaintext: plain, Ciphertext: enc, Header: h})
+		}
+
+		r, err := analyze.RecoverKey(pairs)
+		if err != nil {
+			return err
+		}
+
+		if err := writeImage(*out, r.Image); err != nil {
+			return err
+		}
+		if *known != "" {
+			if err := writeImage(*known, r.Known); err != nil {
+				return err
+			}
+		}
+
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(r)
+		}
+		return printRecoveredKey(os.Stdout, r)
+	}
+	return cmd
+}
+
+// readCiphertextHeader reads a whole ciphertext, the header is nil for JSON ciphertexts.
+// Authentication tags are not verified, the attacks work without the key.
+func readCiphertextHeader(name string) (*crypt.Header, []crypt.PixelPosition, error) {
+	f, err := openInput(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r, err := crypt.NewReader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.SkipAuthentication()
+
+	var out []crypt.PixelPosition
+	for {
+		frame, err := r.Next()
+		if err == io.EOF {
+			return r.Header, out, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		out = append(out, frame...)
+	}
+}
+
+// writeImage writes a greyscale PNG, a partially written file is removed
+func writeImage(name string, i *image.Image) error {
+	o, err := createOutput(name)
+	if err != nil {
+		return err
+	}
+	return o.finish(image.Write(o.File, i))
+}
+
+func printRecoveredKey(w io.Writer, r *analyze.RecoveredKey) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Dimension:\t%dx%d\n", r.Dimension.Width, r.Dimension.Height)
+	fmt.Fprintf(tw, "Recovered pixels:\t%d (%.2f%%)\n", r.Recovered, 100*r.Coverage)
+	fmt.Fprintf(tw, "Symbols:\t%d\n", len(r.Symbols))
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Symbol\tOccurrences\tRecovered\tEstimated\tCoverage")
+	for _, s := range r.Symbols {
+		fmt.Fprintf(tw, "0x%02x %q\t%d\t%d\t%.1f\t%.2f%%\n", s.Symbol, rune(s.Symbol), s.Occurrences, s.Recovered, s.Estimated, 100*s.Coverage)
+	}
+
+	return tw.Flush()
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
 	"os"
 
 	"github.com/go-clix/cli"
@@ -10,58 +14,120 @@ import (
 
 func decryptCmd() *cli.Command {
 	cmd := &cli.Command{
-		Use:   "decrypt",
-		Short: "Decrypt a file using the cipher",
+		Use:   "decrypt <ciphertext> <plaintext>",
+		Short: "Decrypt a file using the cipher, use - for stdin/stdout",
 		Args:  cli.ArgsExact(2),
 	}
 
 	key := cmd.Flags().StringP("key-file", "k", "", "Key File (Image) used for encryption")
+	auth := cmd.Flags().BoolP("auth", "a", false, "Require an authenticated ciphertext")
+	channel := cmd.Flags().StringP("channel", "c", "default", "Key image channel for JSON ciphertexts (default, red, green, blue, alpha, luminance, all)")
+	chain := cmd.Flags().Bool("chain", false, "Decrypt a chained JSON ciphertext")
+	fullByte := cmd.Flags().BoolP("full-byte", "b", false, "Map all 256 byte values (binary safe) instead of 7-bit ASCII")
 
 	cmd.Run = func(cmd *cli.Command, args []string) error {
-		k, err := os.Open(*key)
+		s, err := openInput(args[0])
 		if err != nil {
 			return err
 		}
-		defer k.Close()
+		defer s.Close()
 
-		s, err := os.Open(args[0])
+		// Read the source header without consuming input, authenticated ciphertexts are read twice
+		br := bufio.NewReaderSize(s, headerSize)
+		peek, err := br.Peek(headerSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return err
+		}
+		cr, err := crypt.NewReader(bytes.NewReader(peek))
 		if err != nil {
 			return err
 		}
-		defer s.Close()
 
-		t, err := os.Create(args[1])
+		// Binary ciphertexts carry the key channel in their header
+		ch, err := image.ParseChannel(*channel)
 		if err != nil {
 			return err
 		}
-		defer t.Close()
-
-		img, err := image.Read(k)
+		if cr.Header != nil {
+			ch = cr.Header.Channel
+		}
+		img, err := readKey(*key, ch)
 		if err != nil {
 			return err
 		}
 
-		c, err := crypt.New(img)
+		// Binary ciphertexts carry the mode in their header
+		var opts []crypt.Option
+		if *fullByte || (cr.Header != nil && cr.Header.Mask == image.MaskByte) {
+			opts = append(opts, crypt.WithFullByte())
+		}
+		if *auth {
+			opts = append(opts, crypt.WithAuthentication())
+		}
+		if *chain {
+			opts = append(opts, crypt.WithChaining())
+		}
+
+		c, err := crypt.New(img, opts...)
 		if err != nil {
 			return err
 		}
 
-		// Read source
-		enc, err := crypt.Read(s)
+		// Authenticated plaintext is only released once the whole ciphertext has been verified
+		var dr io.Reader
+		if cr.Header != nil && cr.Header.Authenticated {
+			in, cleanup, sErr := seekableInput(args[0], s, br)
+			if sErr != nil {
+				return sErr
+			}
+			defer cleanup()
+			dr, err = c.NewVerifiedDecryptReader(in)
+		} else {
+			dr, err = c.NewDecryptReader(br)
+		}
 		if err != nil {
 			return err
 		}
 
-		// Decrypt
-		dec, err := c.Decrypt(enc)
+		t, err := createOutput(args[1])
 		if err != nil {
 			return err
 		}
 
-		// Write plaintext to file
-		t.Write([]byte(dec))
-
-		return nil
+		// Decrypt source chunk-wise to the target
+		_, err = io.Copy(t, dr)
+		return t.finish(err)
 	}
 	return cmd
 }
+
+// headerSize is the maximum size of a ciphertext header read by decrypt
+const headerSize = 64 * 1024
+
+// seekableInput returns the ciphertext from its start for reading it twice. A file is rewound,
+// stdin (already partially buffered by br) is spooled to a temporary file; only the ciphertext is
+// written there. The returned function removes the spooled file.
+func seekableInput(name string, f *os.File, br *bufio.Reader) (io.ReadSeeker, func(), error) {
+	if name != "-" {
+		_, err := f.Seek(0, io.SeekStart)
+		return f, func() {}, err
+	}
+
+	spool, err := ioutil.TempFile("", "htw-ciphertext")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}
+	if _, err := io.Copy(spool, br); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return spool, cleanup, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
 
@@ -11,58 +12,165 @@ import (
 
 func encryptCmd() *cli.Command {
 	cmd := &cli.Command{
-		Use:   "encrypt",
-		Short: "Encrypt a file using the cipher",
+		Use:   "encrypt <plaintext> <ciphertext>",
+		Short: "Encrypt a file using the cipher, use - for stdin/stdout",
 		Args:  cli.ArgsExact(2),
 	}
 
 	key := cmd.Flags().StringP("key-file", "k", "", "Key File (Image) used for encryption")
+	lenient := cmd.Flags().Bool("lenient", false, "Skip bytes which can not be represented by the key instead of failing")
+	format := cmd.Flags().StringP("format", "f", string(crypt.FormatBinary), "Ciphertext format (binary, json)")
+	auth := cmd.Flags().BoolP("auth", "a", false, "Authenticate the ciphertext using a tag derived from the key")
+	nonce := cmd.Flags().BoolP("nonce", "n", false, "Permute the pixel coordinates using a random nonce per message")
+	chain := cmd.Flags().Bool("chain", false, "Make the pixel group of every byte depend on the previous position")
+	exhaust := cmd.Flags().String("exhaust", "none", "Pixel reuse within a message (none, reshuffle, error)")
+	ledger := cmd.Flags().String("ledger", "", "Never reuse pixels recorded in this file and record the used ones")
+	flatten := cmd.Flags().Bool("flatten", false, "Flatten the pixel usage frequency assuming English plaintext")
+	corpus := cmd.Flags().String("corpus", "", "Flatten the pixel usage frequency using the distribution of a sample text")
+	channel := cmd.Flags().StringP("channel", "c", "default", "Key image channel (default, red, green, blue, alpha, luminance, all)")
+	fullByte := cmd.Flags().BoolP("full-byte", "b", false, "Map all 256 byte values (binary safe) instead of 7-bit ASCII")
+	seeded := seedFlags(cmd)
 
 	cmd.Run = func(cmd *cli.Command, args []string) error {
-		k, err := os.Open(*key)
+		s, err := openInput(args[0])
 		if err != nil {
 			return err
 		}
-		defer k.Close()
+		defer s.Close()
 
-		s, err := os.Open(args[0])
+		ch, err := image.ParseChannel(*channel)
 		if err != nil {
 			return err
 		}
-		defer s.Close()
-
-		t, err := os.Create(args[1])
+		img, err := readKey(*key, ch)
 		if err != nil {
 			return err
 		}
-		defer t.Close()
 
-		img, err := image.Read(k)
+		var opts []crypt.Option
+		r, err := seeded()
 		if err != nil {
 			return err
 		}
-
-		c, err := crypt.New(img)
+		if r != nil {
+			opts = append(opts, crypt.WithRand(r))
+		}
+		if *fullByte {
+			opts = append(opts, crypt.WithFullByte())
+		}
+		if *auth {
+			opts = append(opts, crypt.WithAuthentication())
+		}
+		if *nonce {
+			opts = append(opts, crypt.WithNonce())
+		}
+		if *chain {
+			opts = append(opts, crypt.WithChaining())
+		}
+		if *corpus != "" {
+			d, err := readDistribution(*corpus)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, crypt.WithFlattening(d))
+		} else if *flatten {
+			opts = append(opts, crypt.WithFlattening(crypt.English()))
+		}
+		e, err := crypt.ParseExhaustion(*exhaust)
 		if err != nil {
 			return err
 		}
+		opts = append(opts, crypt.WithExhaustion(e))
+		var l *crypt.Ledger
+		if *ledger != "" {
+			// Concurrent encryptions must not hand out the same pixels
+			unlock, err := lockFile(*ledger + ".lock")
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			if l, err = readLedger(*ledger, img); err != nil {
+				return err
+			}
+			opts = append(opts, crypt.WithLedger(l))
+		}
+		if *lenient {
+			opts = append(opts, crypt.WithLenient())
+		}
 
-		// Read source
-		sStr, err := ioutil.ReadAll(s)
+		c, err := crypt.New(img, opts...)
 		if err != nil {
 			return err
 		}
 
-		// Encrypt
-		enc, err := c.Encrypt(string(sStr))
+		// encrypt writes the whole ciphertext to w
+		encrypt := func(w io.Writer) error {
+			h, err := c.NewHeader()
+			if err != nil {
+				return err
+			}
+			cw, err := crypt.NewWriter(w, h, crypt.Format(*format))
+			if err != nil {
+				return err
+			}
+
+			ew := c.EncryptFrames(cw)
+			if _, err := io.Copy(ew, s); err != nil {
+				return err
+			}
+			return ew.Close()
+		}
+
+		if l == nil {
+			t, err := createOutput(args[1])
+			if err != nil {
+				return err
+			}
+			// Encrypt source chunk-wise to the target, a partial ciphertext is removed on failure
+			return t.finish(encrypt(t))
+		}
+
+		// The consumed pixels are persisted before the ciphertext is released: a failure in
+		// between wastes pixels but never leads to reusing them
+		spool, err := ioutil.TempFile("", "htw-encrypt")
 		if err != nil {
 			return err
 		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
 
-		// Write ciphertext to file
-		crypt.Write(t, enc)
+		if err := encrypt(spool); err != nil {
+			return err
+		}
+		if err := writeLedger(*ledger, l); err != nil {
+			return err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
 
+		t, err := createOutput(args[1])
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(t, spool)
+		if err := t.finish(err); err != nil {
+			return err
+		}
+		printCapacity(os.Stderr, c)
 		return nil
 	}
 	return cmd
 }
+
+// readDistribution computes the byte distribution of a corpus file
+func readDistribution(name string) (crypt.Distribution, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return crypt.Distribution{}, err
+	}
+	defer f.Close()
+
+	return crypt.DistributionFromCorpus(f)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/go-clix/cli"
+	analyze "github.com/xvzf/htw-crypto-project/pkg/crypt/analyze"
+	"github.com/xvzf/htw-crypto-project/pkg/eval"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+	"github.com/xvzf/htw-crypto-project/pkg/lm"
+)
+
+func evalCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "eval <corpus>",
+		Short: "Measure the success of the attacks depending on the amount of ciphertext",
+		Args:  cli.ArgsExact(1),
+	}
+
+	var attacks []string
+	for _, a := range eval.Attacks {
+		attacks = append(attacks, string(a))
+	}
+
+	width := cmd.Flags().Int("width", 256, "Width of the generated keys")
+	height := cmd.Flags().Int("height", 256, "Height of the generated keys")
+	lengths := cmd.Flags().IntSliceP("lengths", "l", []int{1000, 10000}, "Plaintext lengths")
+	repetitions := cmd.Flags().IntSliceP("repetitions", "r", []int{1, 10}, "Number of encryptions of the same plaintext")
+	trials := cmd.Flags().IntP("trials", "t", 3, "Trials per parameter combination")
+	attack := cmd.Flags().StringP("attacks", "a", strings.Join(attacks, ","), "Comma separated attacks")
+	clusterer := cmd.Flags().StringP("clusterer", "c", "stdev", "Clustering strategy of the frequency attack")
+	model := cmd.Flags().StringP("model", "m", "", "Language model trained using lm train, by default it is trained on the corpus")
+	normalize := cmd.Flags().StringP("normalize", "n", "words", fmt.Sprintf("Alphabet normalization of the corpus (%s)", normalizationNames()))
+	restarts := cmd.Flags().Int("restarts", 4, "Number of annealing runs of the solver")
+	iterations := cmd.Flags().Int("iterations", 1000, "Solver iterations per unit and run")
+	seed := cmd.Flags().Int64("seed", 1, "Seed of the plaintext excerpts, keys, encryptions and solver, makes the results reproducible")
+	format := cmd.Flags().StringP("format", "f", "csv", "Output format (csv, json)")
+	out := cmd.Flags().StringP("output", "o", "-", "Write the results to this file")
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		if *format != "csv" && *format != "json" {
+			return fmt.Errorf("unknown format %q", *format)
+		}
+
+		var m *lm.Model
+		var err error
+		if *model != "" {
+			m, err = readModel(*model)
+		} else {
+			m, err = trainModel(args[0], *normalize, "")
+		}
+		if err != nil {
+			return err
+		}
+
+		corpus, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+
+		c := &eval.Config{
+			Dimension:   image.Dimension{Width: *width, Height: *height},
+			Lengths:     *lengths,
+			Repetitions: *repetitions,
+			Trials:      *trials,
+			Model:       m,
+			Corpus:      m.Normalize(corpus),
+			Seed:        *seed,
+			Solver:      analyze.NewSolver(m),
+		}
+		c.Solver.Restarts = *restarts
+		c.Solver.Iterations = *iterations
+
+		for _, name := range strings.Split(*attack, ",") {
+			a, err := eval.ParseAttack(name)
+			if err != nil {
+				return err
+			}
+			c.Attacks = append(c.Attacks, a)
+		}
+		if c.Clusterer, err = analyze.NewClusterer(*clusterer); err != nil {
+			return err
+		}
+
+		o, err := createOutput(*out)
+		if err != nil {
+			return err
+		}
+
+		// CSV rows are written as soon as they are available
+		if *format == "csv" {
+			w := csv.NewWriter(o)
+			w.Write(eval.CSVHeader)
+			err = eval.Run(c, func(r eval.Result) error {
+				w.Write(r.CSV())
+				w.Flush()
+				return w.Error()
+			})
+			return o.finish(err)
+		}
+
+		results := []eval.Result{}
+		err = eval.Run(c, func(r eval.Result) error {
+			results = append(results, r)
+			return nil
+		})
+		if err == nil {
+			enc := json.NewEncoder(o)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(results)
+		}
+		return o.finish(err)
+	}
+	return cmd
+}
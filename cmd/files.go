@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+// openInput opens a file for reading, "-" refers to stdin
+func openInput(name string) (*os.File, error) {
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+// openReader opens a ciphertext for analyze.LoadFiles, "-" refers to stdin
+func openReader(name string) (io.ReadCloser, error) {
+	return openInput(name)
+}
+
+// spoolStdin copies stdin to a temporary file if "-" is among the names, for inputs which are
+// read more than once. The returned function removes the temporary file.
+func spoolStdin(names []string) ([]string, func(), error) {
+	out := append([]string{}, names...)
+	spooled := ""
+	for i, name := range out {
+		if name != "-" {
+			continue
+		}
+		if spooled == "" {
+			f, err := ioutil.TempFile("", "htw-stdin")
+			if err != nil {
+				return nil, nil, err
+			}
+			spooled = f.Name()
+			_, err = io.Copy(f, os.Stdin)
+			if cErr := f.Close(); err == nil {
+				err = cErr
+			}
+			if err != nil {
+				os.Remove(spooled)
+				return nil, nil, err
+			}
+		}
+		out[i] = spooled
+	}
+
+	return out, func() {
+		if spooled != "" {
+			os.Remove(spooled)
+		}
+	}, nil
+}
+
+// output is a target file which is removed again when the command fails
+type output struct {
+	*os.File
+	temporary bool
+}
+
+// createOutput creates a file for writing, "-" refers to stdout
+func createOutput(name string) (*output, error) {
+	if name == "-" {
+		return &output{File: os.Stdout}, nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &output{File: f, temporary: true}, nil
+}
+
+// finish closes the output; if err is set, a created file is removed and err returned
+func (o *output) finish(err error) error {
+	if o.File == os.Stdout {
+		return err
+	}
+
+	if cErr := o.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil && o.temporary {
+		os.Remove(o.Name())
+	}
+	return err
+}
+
+// readKey loads a key image using the given channel
+func readKey(name string, ch image.Channel) (*image.Image, error) {
+	k, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Close()
+
+	return image.ReadChannel(k, ch)
+}
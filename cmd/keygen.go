@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/go-clix/cli"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+func keygenCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "keygen <key>",
+		Short: "Generate a random key image (PNG)",
+		Args:  cli.ArgsExact(1),
+	}
+
+	width := cmd.Flags().Int("width", 512, "Width of the key image")
+	height := cmd.Flags().Int("height", 512, "Height of the key image")
+	minPixels := cmd.Flags().IntP("min-pixels", "n", 1, "Minimum number of pixels representing every symbol")
+	balance := cmd.Flags().Bool("balance", false, "Distribute the pixels evenly across all symbols")
+	fullByte := cmd.Flags().BoolP("full-byte", "b", false, "Represent all 256 byte values instead of 7-bit ASCII")
+	seeded := seedFlags(cmd)
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		opts := image.GenerateOptions{
+			Mask:      image.MaskASCII,
+			MinPixels: *minPixels,
+			Balance:   *balance,
+		}
+		if *fullByte {
+			opts.Mask = image.MaskByte
+		}
+		r, err := seeded()
+		if err != nil {
+			return err
+		}
+		opts.Rand = r
+
+		img, err := image.Generate(image.Dimension{Width: *width, Height: *height}, opts)
+		if err != nil {
+			return err
+		}
+
+		// A partially written key is removed on failure
+		t, err := createOutput(args[0])
+		if err != nil {
+			return err
+		}
+		return t.finish(image.Write(t.File, img))
+	}
+	return cmd
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/go-clix/cli"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+func keyinfoCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "keyinfo <key>",
+		Short: "Report the quality of a key image",
+		Args:  cli.ArgsExact(1),
+	}
+
+	minHomophones := cmd.Flags().IntP("min-homophones", "n", image.DefaultMinHomophones, "Number of pixels below which a symbol is reported as weak")
+	channel := cmd.Flags().StringP("channel", "c", "default", "Key image channel (default, red, green, blue, alpha, luminance, all)")
+	fullByte := cmd.Flags().BoolP("full-byte", "b", false, "Assess all 256 byte values instead of 7-bit ASCII")
+	ledger := cmd.Flags().String("ledger", "", "Report the remaining capacity of the key recorded in this ledger")
+	asJSON := cmd.Flags().Bool("json", false, "Output the report as JSON")
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		ch, err := image.ParseChannel(*channel)
+		if err != nil {
+			return err
+		}
+		img, err := readKey(args[0], ch)
+		if err != nil {
+			return err
+		}
+
+		mask := image.MaskASCII
+		if *fullByte {
+			mask = image.MaskByte
+		}
+
+		a := image.Assess(img, mask, *minHomophones)
+
+		var c *crypt.Container
+		if *ledger != "" {
+			opts := []crypt.Option{}
+			if *fullByte {
+				opts = append(opts, crypt.WithFullByte())
+			}
+			l, err := readLedger(*ledger, img)
+			if err != nil {
+				return err
+			}
+			if c, err = crypt.New(img, append(opts, crypt.WithLedger(l))...); err != nil {
+				return err
+			}
+		}
+
+		if *asJSON {
+			report := struct {
+				*image.Assessment
+				Capacity *capacity `json:"capacity,omitempty"`
+			}{Assessment: a}
+			if c != nil {
+				report.Capacity = newCapacity(c)
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+		if err := printAssessment(os.Stdout, a); err != nil {
+			return err
+		}
+		if c != nil {
+			fmt.Fprintln(os.Stdout)
+			printCapacity(os.Stdout, c)
+		}
+		return nil
+	}
+	return cmd
+}
+
+func printAssessment(w io.Writer, a *image.Assessment) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Dimension:\t%dx%d\n", a.Dimension.Width, a.Dimension.Height)
+	fmt.Fprintf(tw, "Symbols:\t%d (mask 0x%02x)\n", a.Symbols, a.Mask)
+	fmt.Fprintf(tw, "Pixels per symbol:\tmin %d, max %d, mean %.1f\n", a.Min, a.Max, a.Mean)
+	fmt.Fprintf(tw, "Entropy:\t%.3f of %.3f bits\n", a.Entropy, a.MaxEntropy)
+	fmt.Fprintf(tw, "Missing symbols:\t%d\n", len(a.Missing))
+	fmt.Fprintf(tw, "Weak symbols (< %d pixels):\t%d\n", a.MinHomophones, len(a.Weak))
+	fmt.Fprintf(tw, "Grade:\t%s\n", a.Grade)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(a.Missing) > 0 {
+		fmt.Fprintf(w, "\nMissing: %v\n", a.Missing)
+	}
+	if len(a.Weak) > 0 {
+		fmt.Fprintln(w, "\nWeak symbols:")
+		for _, g := range a.Weak {
+			fmt.Fprintf(w, "  0x%02x %q: %d pixels\n", g.Symbol, rune(g.Symbol), g.Pixels)
+		}
+	}
+
+	return nil
+}
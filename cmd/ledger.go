@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+// readLedger loads the ledger of a key, a missing file yields an empty ledger
+func readLedger(name string, img *image.Image) (*crypt.Ledger, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return crypt.NewLedger(img)
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return crypt.LoadLedger(f)
+}
+
+// writeLedger replaces the ledger file atomically, the new content is on disk before the
+// rename. The caller has to hold the ledger lock.
+func writeLedger(name string, l *crypt.Ledger) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	if err := l.Save(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// capacity summarizes the unused pixels of a container's key
+type capacity struct {
+	Total int `json:"total"`
+	// Remaining contains the unused pixels per symbol
+	Remaining map[uint8]int `json:"remaining"`
+	// Least is the symbol with the fewest unused pixels
+	Least       uint8 `json:"least"`
+	LeastPixels int   `json:"least_pixels"`
+}
+
+func newCapacity(c *crypt.Container) *capacity {
+	out := &capacity{Remaining: c.Remaining(), LeastPixels: -1}
+	for v, n := range out.Remaining {
+		out.Total += n
+		if out.LeastPixels < 0 || n < out.LeastPixels || (n == out.LeastPixels && v < out.Least) {
+			out.LeastPixels, out.Least = n, v
+		}
+	}
+	return out
+}
+
+// printCapacity reports the unused pixels of a container's key
+func printCapacity(w io.Writer, c *crypt.Container) {
+	r := newCapacity(c)
+	fmt.Fprintf(w, "Remaining capacity: %d pixels, least 0x%02x %q with %d pixels\n", r.Total, r.Least, rune(r.Least), r.LeastPixels)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-clix/cli"
+	"github.com/xvzf/htw-crypto-project/pkg/lm"
+)
+
+func lmCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "lm",
+		Short: "Language models used for scoring plaintexts",
+	}
+
+	cmd.AddCommand(
+		lmTrainCmd(),
+	)
+	return cmd
+}
+
+// normalizationNames returns the names of the predefined normalizations
+func normalizationNames() string {
+	names := make([]string, 0, len(lm.Normalizations))
+	for name := range lm.Normalizations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func lmTrainCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "train <corpus> <model>",
+		Short: "Train unigram to quadgram probabilities on a text corpus",
+		Args:  cli.ArgsExact(2),
+	}
+
+	normalize := cmd.Flags().StringP("normalize", "n", "words", fmt.Sprintf("Alphabet normalization (%s)", normalizationNames()))
+	alphabet := cmd.Flags().String("alphabet", "", "Custom alphabet, overrides the alphabet of the normalization")
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		m, err := trainModel(args[0], *normalize, *alphabet)
+		if err != nil {
+			return err
+		}
+
+		o, err := createOutput(args[1])
+		if err != nil {
+			return err
+		}
+		if err := o.finish(m.Save(o)); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Trained on %d symbols of alphabet %q\n", m.Counts[0], m.Alphabet())
+		return nil
+	}
+	return cmd
+}
+
+// trainModel trains a language model on a corpus file
+func trainModel(corpus, normalize, alphabet string) (*lm.Model, error) {
+	n, err := lm.ParseNormalization(normalize)
+	if err != nil {
+		return nil, err
+	}
+	if alphabet != "" {
+		n.Alphabet = alphabet
+	}
+
+	f, err := openInput(corpus)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return lm.Train(f, n)
+}
+
+// readModel loads a language model file
+func readModel(name string) (*lm.Model, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return lm.Load(f)
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockFile takes an exclusive lock on name by creating it, the file must not exist yet.
+// The returned function releases the lock.
+func lockFile(name string) (func(), error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return nil, fmt.Errorf("%s is locked by another process, remove it if no encryption is running", name)
+	} else if err != nil {
+		return nil, err
+	}
+	return func() {
+		f.Close()
+		os.Remove(name)
+	}, nil
+}
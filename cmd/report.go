@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"text/tabwriter"
+
+	"github.com/go-clix/cli"
+	analyze "github.com/xvzf/htw-crypto-project/pkg/crypt/analyze"
+)
+
+// report is the output of analyze report
+type report struct {
+	Files []string `json:"files"`
+	*analyze.Report
+}
+
+func analyzeReportCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "report <ciphertext>...",
+		Short: "Report the statistics of one or more ciphertexts",
+		Args:  argsMin(1),
+	}
+
+	clusterer := cmd.Flags().StringP("clusterer", "c", "stdev", "Clustering strategy")
+	groups := cmd.Flags().IntP("groups", "n", 26, "Number of homophone groups")
+	bins := cmd.Flags().Int("bins", 20, "Number of histogram bins")
+	format := cmd.Flags().StringP("format", "f", "text", "Output format (text, json, html)")
+	out := cmd.Flags().StringP("output", "o", "-", "Write the report to this file")
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		var write func(io.Writer, *report) error
+		switch *format {
+		case "text":
+			write = printReport
+		case "json":
+			write = func(w io.Writer, r *report) error {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				return enc.Encode(r)
+			}
+		case "html":
+			write = func(w io.Writer, r *report) error {
+				return reportTemplate.Execute(w, r)
+			}
+		default:
+			return fmt.Errorf("unknown format %q", *format)
+		}
+
+		c, err := analyze.NewClusterer(*clusterer)
+		if err != nil {
+			return err
+		}
+
+		a, err := analyze.LoadFiles(args, openReader, 0)
+		if err != nil {
+			return err
+		}
+
+		r, err := a.Report(c, *groups, *bins)
+		if err != nil {
+			return err
+		}
+
+		o, err := createOutput(*out)
+		if err != nil {
+			return err
+		}
+		return o.finish(write(o, &report{Files: args, Report: r}))
+	}
+	return cmd
+}
+
+func printReport(w io.Writer, r *report) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Files:\t%d\n", len(r.Files))
+	fmt.Fprintf(tw, "Total symbols:\t%d\n", r.Total)
+	fmt.Fprintf(tw, "Distinct positions:\t%d\n", r.Distinct)
+	fmt.Fprintf(tw, "Inferred dimension:\t%dx%d\n", r.Dimension.Width, r.Dimension.Height)
+	fmt.Fprintf(tw, "Index of coincidence:\t%.6f\n", r.IndexOfCoincidence)
+
+	fmt.Fprintln(tw, "\nFrequency\tPositions\t")
+	for _, b := range r.Histogram {
+		fmt.Fprintf(tw, "%d-%d\t%d\t%s\n", b.From, b.To, b.Positions, bar(b.Positions, r.Distinct, 40))
+	}
+
+	fmt.Fprintln(tw, "\nCluster\tPositions\tTotal\tFrequency (min/mean/max)\tStdev")
+	for i, c := range r.Clusters {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d/%.1f/%d\t%.2f\n", i, c.Positions, c.Total, c.Min, c.Mean, c.Max, c.Stdev)
+	}
+
+	return tw.Flush()
+}
+
+// bar returns a text bar of width scaled relative to total
+func bar(v, total, width int) string {
+	if total == 0 {
+		return ""
+	}
+	out := make([]byte, v*width/total)
+	for i := range out {
+		out[i] = '#'
+	}
+	return string(out)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"histogramMax": func(bins []analyze.HistogramBin) int {
+		out := 1
+		for _, b := range bins {
+			if b.Positions > out {
+				out = b.Positions
+			}
+		}
+		return out
+	},
+	"clusterMax": func(clusters []analyze.ClusterSummary) int {
+		out := 1
+		for _, c := range clusters {
+			if c.Total > out {
+				out = c.Total
+			}
+		}
+		return out
+	},
+	"scale": func(v, max int, length float64) float64 {
+		return float64(v) * length / float64(max)
+	},
+	"offset": func(i int, width, start float64) float64 {
+		return start + float64(i)*width
+	},
+	"subf": func(a, b float64) float64 {
+		return a - b
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Ciphertext analysis</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em; text-align: right; border-bottom: 1px solid #ddd; }
+svg { background: #fafafa; }
+rect { fill: #c0392b; }
+text { font-size: 10px; }
+</style>
+</head>
+<body>
+<h1>Ciphertext analysis</h1>
+<table>
+<tr><th>Files</th><td>{{range $i, $f := .Files}}{{if $i}}, {{end}}{{$f}}{{end}}</td></tr>
+<tr><th>Total symbols</th><td>{{.Total}}</td></tr>
+<tr><th>Distinct positions</th><td>{{.Distinct}}</td></tr>
+<tr><th>Inferred dimension</th><td>{{.Dimension.Width}}x{{.Dimension.Height}}</td></tr>
+<tr><th>Index of coincidence</th><td>{{printf "%.6f" .IndexOfCoincidence}}</td></tr>
+</table>
+
+<h2>Frequency histogram</h2>
+{{$max := histogramMax .Histogram}}
+<svg width="{{offset (len .Histogram) 24 40}}" height="240" xmlns="http://www.w3.org/2000/svg">
+{{range $i, $b := .Histogram}}{{$h := scale $b.Positions $max 200}}
+<rect x="{{offset $i 24 40}}" y="{{subf 210 $h}}" width="20" height="{{$h}}"><title>{{$b.From}}-{{$b.To}}: {{$b.Positions}} positions</title></rect>
+<text x="{{offset $i 24 40}}" y="230">{{$b.From}}</text>
+{{end}}
+<text x="0" y="10">{{$max}}</text>
+</svg>
+
+<h2>Clusters</h2>
+{{$cmax := clusterMax .Clusters}}
+<svg width="{{offset (len .Clusters) 24 40}}" height="240" xmlns="http://www.w3.org/2000/svg">
+{{range $i, $c := .Clusters}}{{$h := scale $c.Total $cmax 200}}
+<rect x="{{offset $i 24 40}}" y="{{subf 210 $h}}" width="20" height="{{$h}}"><title>Cluster {{$i}}: {{$c.Positions}} positions, {{$c.Total}} symbols</title></rect>
+<text x="{{offset $i 24 40}}" y="230">{{$i}}</text>
+{{end}}
+<text x="0" y="10">{{$cmax}}</text>
+</svg>
+<table>
+<tr><th>Cluster</th><th>Positions</th><th>Total</th><th>Min</th><th>Mean</th><th>Max</th><th>Stdev</th></tr>
+{{range $i, $c := .Clusters}}<tr><td>{{$i}}</td><td>{{$c.Positions}}</td><td>{{$c.Total}}</td><td>{{$c.Min}}</td><td>{{printf "%.1f" $c.Mean}}</td><td>{{$c.Max}}</td><td>{{printf "%.2f" $c.Stdev}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -17,6 +17,12 @@ func main() {
 	rootCmd.AddCommand(
 		encryptCmd(),
 		decryptCmd(),
+		keygenCmd(),
+		keyinfoCmd(),
+		analyzeCmd(),
+		attackCmd(),
+		lmCmd(),
+		evalCmd(),
 	)
 
 	// run and check for errors
@@ -25,3 +31,16 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// argsMin checks that at least n arguments were given
+func argsMin(n int) cli.Arguments {
+	return cli.Args{
+		Validator: cli.ValidateFunc(func(args []string) error {
+			if len(args) < n {
+				return fmt.Errorf("accepts at least %d arg(s), received %d", n, len(args))
+			}
+			return nil
+		}),
+		Predictor: cli.ArgsAny(),
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-clix/cli"
+	"github.com/xvzf/htw-crypto-project/pkg/drbg"
+)
+
+// seedFlags adds the flags selecting a deterministic random source for lab use
+func seedFlags(cmd *cli.Command) func() (io.Reader, error) {
+	seed := cmd.Flags().String("seed", "", "Derive all randomness from this seed (insecure, requires --test-mode)")
+	testMode := cmd.Flags().Bool("test-mode", false, "Allow insecure options meant for reproducible experiments")
+
+	return func() (io.Reader, error) {
+		if *seed == "" {
+			return nil, nil
+		}
+		if !*testMode {
+			return nil, errors.New("--seed makes the output predictable to anybody knowing the seed, it requires --test-mode")
+		}
+		fmt.Fprintln(os.Stderr, "WARNING: randomness is derived from --seed, never use the output for real data")
+		return drbg.New([]byte(*seed)), nil
+	}
+}
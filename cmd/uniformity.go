@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/go-clix/cli"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	analyze "github.com/xvzf/htw-crypto-project/pkg/crypt/analyze"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+func analyzeUniformityCmd() *cli.Command {
+	cmd := &cli.Command{
+		Use:   "uniformity <ciphertext>...",
+		Short: "Test whether the pixels of every group are selected uniformly (requires the key)",
+		Args:  argsMin(1),
+	}
+
+	key := cmd.Flags().StringP("key-file", "k", "", "Key File (Image) used for encryption")
+	channel := cmd.Flags().StringP("channel", "c", "default", "Key image channel for JSON ciphertexts (default, red, green, blue, alpha, luminance, all)")
+	fullByte := cmd.Flags().BoolP("full-byte", "b", false, "Map all 256 byte values (binary safe) instead of 7-bit ASCII")
+	top := cmd.Flags().IntP("top", "n", 10, "Number of groups with the lowest p-value to list")
+	asJSON := cmd.Flags().Bool("json", false, "Output the result as JSON")
+
+	cmd.Run = func(cmd *cli.Command, args []string) error {
+		ch, err := image.ParseChannel(*channel)
+		if err != nil {
+			return err
+		}
+		mask := image.MaskASCII
+		if *fullByte {
+			mask = image.MaskByte
+		}
+
+		// The first ciphertext is read twice
+		args, cleanup, err := spoolStdin(args)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		// Binary ciphertexts carry the key channel and mask in their header
+		h, err := readHeader(args[0])
+		if err != nil {
+			return err
+		}
+		if h != nil {
+			if h.Nonce != nil {
+				return fmt.Errorf("%s: positions are permuted using a nonce", args[0])
+			}
+			if h.Flattened {
+				return fmt.Errorf("%s: flattened ciphertexts only draw from a subset of every group", args[0])
+			}
+			ch, mask = h.Channel, h.Mask
+		}
+
+		img, err := readKey(*key, ch)
+		if err != nil {
+			return err
+		}
+
+		a, err := analyze.LoadFiles(args, openReader, 0)
+		if err != nil {
+			return err
+		}
+		u, err := a.Uniformity(crypt.ExtractGroupsMask(img, mask))
+		if err != nil {
+			return err
+		}
+
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(u)
+		}
+		return printUniformity(os.Stdout, u, *top)
+	}
+	return cmd
+}
+
+// readHeader returns the header of a ciphertext, nil for JSON ciphertexts
+func readHeader(name string) (*crypt.Header, error) {
+	f, err := openInput(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := crypt.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return r.Header, nil
+}
+
+func printUniformity(w io.Writer, u *analyze.Uniformity, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Groups:\t%d\n", len(u.Groups))
+	fmt.Fprintf(tw, "Chi-square:\t%.1f (%d degrees of freedom)\n", u.ChiSquare, u.DegreesOfFreedom)
+	fmt.Fprintf(tw, "p-value:\t%.3g\n", u.PValue)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "Symbol\tPixels\tDraws\tChi-square\tp-value")
+	for i, g := range u.Groups {
+		if i >= top {
+			break
+		}
+		fmt.Fprintf(tw, "0x%02x %q\t%d\t%d\t%.1f\t%.3g\n", g.Symbol, rune(g.Symbol), g.Pixels, g.Draws, g.ChiSquare, g.PValue)
+	}
+
+	return tw.Flush()
+}
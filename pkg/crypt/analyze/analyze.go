@@ -2,8 +2,11 @@ package crypt
 
 import (
 	"fmt"
-	"math"
+	"io"
+	"os"
+	"runtime"
 	"sort"
+	"sync"
 
 	"github.com/xvzf/htw-crypto-project/pkg/crypt"
 	"github.com/xvzf/htw-crypto-project/pkg/image"
@@ -38,121 +41,240 @@ func expand(a, b crypt.PixelPosition) crypt.PixelPosition {
 
 // Load ciphertext
 func Load(in []crypt.PixelPosition) *Analyse {
+	a := &Analyse{}
+	a.Add(in)
+	return a
+}
 
-	a := &Analyse{
-		Total:     len(in),
-		Frequency: make(map[crypt.PixelPosition]int),
+// Add updates the analysis with further ciphertext, memory is bounded by the number of
+// distinct positions
+func (a *Analyse) Add(in []crypt.PixelPosition) {
+	if a.Frequency == nil {
+		a.Frequency = make(map[crypt.PixelPosition]int)
 	}
-
-	tmpDim := crypt.PixelPosition{Width: 0, Height: 0}
+	a.Total += len(in)
 
 	// Retrieve pixelposition frequency & potential image dimensions
 	for _, ppos := range in {
+		a.Frequency[ppos]++
+		a.grow(ppos)
+	}
+}
 
-		// Update image dimensions
-		tmpDim = expand(tmpDim, ppos)
-
-		// Frequency
-		if v, ok := a.Frequency[ppos]; ok {
-			a.Frequency[ppos] = v + 1
-		} else {
-			a.Frequency[ppos] = 1
-		}
+// Merge adds the statistics of another analysis
+func (a *Analyse) Merge(o *Analyse) {
+	if a.Frequency == nil {
+		a.Frequency = make(map[crypt.PixelPosition]int, len(o.Frequency))
 	}
+	a.Total += o.Total
 
-	// Update expected image dimension
-	a.ExpectedDimension = image.Dimension{Width: tmpDim.Width + 1, Height: tmpDim.Height + 1}
+	for ppos, v := range o.Frequency {
+		a.Frequency[ppos] += v
+	}
+	if o.ExpectedDimension.Width > 0 && o.ExpectedDimension.Height > 0 {
+		a.grow(crypt.PixelPosition{Width: o.ExpectedDimension.Width - 1, Height: o.ExpectedDimension.Height - 1})
+	}
+}
 
-	return a
+// grow updates the expected image dimension to contain a position
+func (a *Analyse) grow(ppos crypt.PixelPosition) {
+	a.ExpectedDimension = image.Dimension{
+		Width:  max(a.ExpectedDimension.Width, ppos.Width+1),
+		Height: max(a.ExpectedDimension.Height, ppos.Height+1),
+	}
 }
 
-// stat returns mean & standard deviation
-func stat(in []PixelPositionFrequency) (float64, float64) {
-	var sum int
-	var sumSquared int
-	var stdev float64
-	var mean float64
+// Opener opens a named ciphertext for reading
+type Opener func(name string) (io.ReadCloser, error)
 
-	sum = 0
-	sumSquared = 0
-	for _, v := range in {
-		sum += v.Count
-		sumSquared += v.Count * v.Count
+// LoadFiles analyses ciphertext files using concurrent workers (runtime.NumCPU() if workers
+// is <= 0). Files are opened using open (os.Open if nil) and streamed frame by frame, memory
+// is bounded by the number of distinct positions per worker.
+func LoadFiles(names []string, open Opener, workers int) (*Analyse, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if open == nil {
+		open = func(name string) (io.ReadCloser, error) {
+			return os.Open(name)
+		}
+	}
+
+	jobs := make(chan string)
+	results := make(chan *Analyse, workers)
+	errs := make(chan error, len(names))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a := &Analyse{}
+			for name := range jobs {
+				if err := a.addFile(open, name); err != nil {
+					errs <- fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			results <- a
+		}()
 	}
 
-	mean = float64(sum) / float64(len(in))
-	stdev = math.Sqrt((float64(sumSquared) / float64(len(in))) - mean*mean)
+	for _, name := range names {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return nil, err
+	}
 
-	return mean, stdev
+	out := &Analyse{Frequency: make(map[crypt.PixelPosition]int)}
+	for a := range results {
+		out.Merge(a)
+	}
+	return out, nil
 }
 
-func sum(in []PixelPositionFrequency) int {
-	sum := 0
-	for _, v := range in {
-		sum += v.Count
+// addFile streams a ciphertext file into the analysis
+func (a *Analyse) addFile(open Opener, name string) error {
+	f, err := open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := crypt.NewReader(f)
+	if err != nil {
+		return err
+	}
+	// The analysis works without the key
+	r.SkipAuthentication()
+	for {
+		frame, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		a.Add(frame)
 	}
-	return sum
 }
 
-func extractDatapoints(in []PixelPositionFrequency) []int {
-	var out []int
-	for _, v := range in {
-		out = append(out, v.Count)
+// frequencies returns the pixel position frequencies
+func (a *Analyse) frequencies() []PixelPositionFrequency {
+	var out []PixelPositionFrequency
+	for k, v := range a.Frequency {
+		out = append(out, PixelPositionFrequency{
+			PixelPosition: k,
+			Count:         v,
+		})
 	}
 	return out
 }
 
-func cluster(in []PixelPositionFrequency, minStdev float64) [][]PixelPositionFrequency {
-	var clusters [][]PixelPositionFrequency
+// ExtractGroups clusters the pixel positions by their frequency into n groups using the
+// StdevWalker, each group is expected to represent one plaintext symbol. It exploits the
+// uniform distribution of a pseudo-random generator. For n <= 0 the number of groups is
+// determined by scaling the minimum standard deviation with the number of positions.
+func (a *Analyse) ExtractGroups(n int) ([]PixelGroupFrequency, error) {
+	return a.ExtractGroupsWith(StdevWalker{}, n)
+}
 
-	var cluster []PixelPositionFrequency
+// ExtractGroupsWith clusters the pixel positions by their frequency into n groups using
+// the given clustering strategy
+func (a *Analyse) ExtractGroupsWith(c Clusterer, n int) ([]PixelGroupFrequency, error) {
+	sorted := a.frequencies()
+	if len(sorted) == 0 {
+		return nil, nil
+	}
 
-	// Sort input data for clustering based on standard deviation
-	sort.SliceStable(in, func(i, j int) bool {
-		return in[i].Count < in[j].Count
+	// Clusterers expect the frequencies in ascending order
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Count < sorted[j].Count
 	})
 
-	// Iterate sorted PixelPositions based on their count
-	for _, v := range in {
+	clusters, err := c.Cluster(sorted, n)
+	if err != nil {
+		return nil, err
+	}
 
-		// First two values go into a cluster
-		if len(cluster) < 2 {
-			cluster = append(cluster, v)
-			continue
+	groups := make([]PixelGroupFrequency, len(clusters))
+	for i, c := range clusters {
+		for _, v := range c {
+			groups[i].PixelPositions = append(groups[i].PixelPositions, v.PixelPosition)
+			groups[i].Total += v.Count
 		}
+	}
 
-		// Calculate mean&stdev of cluster
-		mean, stdev := stat(cluster)
-		if stdev < minStdev {
-			stdev = minStdev
-		}
+	return groups, nil
+}
 
-		if math.Abs(mean-float64(v.Count)) > stdev {
-			clusters = append(clusters, cluster)
-			fmt.Println(extractDatapoints(cluster))
-			cluster = []PixelPositionFrequency{}
-		} else {
-			cluster = append(cluster, v)
+// WithinSumOfSquares returns the sum of squared deviations of the position frequencies
+// from their group mean, a measure for the compactness of a clustering
+func (a *Analyse) WithinSumOfSquares(groups []PixelGroupFrequency) float64 {
+	var out float64
+	for _, g := range groups {
+		var m moments
+		for _, p := range g.PixelPositions {
+			m.add(a.Frequency[p])
+		}
+		if m.n > 0 {
+			_, sd := m.stat()
+			out += sd * sd * float64(m.n)
 		}
 	}
+	return out
+}
 
-	return clusters
+// Substitute transforms the ciphertext into a monoalphabetic substitution cipher: all
+// positions of a group are replaced by the same symbol of the alphabet, the most frequent
+// group by the first symbol.
+func Substitute(in []crypt.PixelPosition, groups []PixelGroupFrequency, alphabet string) (string, error) {
+	s, err := NewSubstitution(groups, alphabet)
+	if err != nil {
+		return "", err
+	}
+	out, err := s.Apply(in)
+	return string(out), err
 }
 
-// Extract Groups by clustering the pixel frequency into clusters
-// It exploits the uniform distribution of a pseudo-random generator.
-func (a *Analyse) ExtractGroups() [][]PixelGroupFrequency {
-	var sorted []PixelPositionFrequency
-	for k, v := range a.Frequency {
-		sorted = append(sorted, PixelPositionFrequency{
-			PixelPosition: k,
-			Count:         v,
-		})
+// Substitution maps the pixel positions to the symbols of a monoalphabetic substitution cipher
+type Substitution map[crypt.PixelPosition]byte
+
+// NewSubstitution replaces all positions of a group by the same symbol of the alphabet, the
+// most frequent group by the first symbol
+func NewSubstitution(groups []PixelGroupFrequency, alphabet string) (Substitution, error) {
+	if len(groups) > len(alphabet) {
+		return nil, fmt.Errorf("%d groups can not be represented by an alphabet of %d symbols", len(groups), len(alphabet))
 	}
 
-	scalingFactor := float64(len(sorted)) * 0.0025
+	ranked := append([]PixelGroupFrequency{}, groups...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Total > ranked[j].Total
+	})
 
-	fmt.Println(len(cluster(sorted, scalingFactor)))
+	symbols := make(Substitution)
+	for i, g := range ranked {
+		for _, p := range g.PixelPositions {
+			symbols[p] = alphabet[i]
+		}
+	}
+	return symbols, nil
+}
 
-	return nil
+// Apply substitutes the positions, which can be applied to a ciphertext frame by frame
+func (s Substitution) Apply(in []crypt.PixelPosition) ([]byte, error) {
+	out := make([]byte, len(in))
+	for i, p := range in {
+		symbol, ok := s[p]
+		if !ok {
+			return nil, fmt.Errorf("position %dx%d is not part of any group", p.Width, p.Height)
+		}
+		out[i] = symbol
+	}
+	return out, nil
 }
@@ -1,11 +1,18 @@
 package crypt
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	"github.com/xvzf/htw-crypto-project/pkg/drbg"
 	"github.com/xvzf/htw-crypto-project/pkg/image"
 )
 
@@ -33,13 +40,13 @@ func init() {
 		i = image.Mock()
 	}
 
-	// Build new crypt engine
-	cipher, err = crypt.New(i)
+	// Build new crypt engine, seeded for reproducible test runs
+	cipher, err = crypt.New(i, crypt.WithRand(drbg.New([]byte("test"))))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	blindText256Enc = make([]crypt.PixelPosition, len(blindText)*256)
+	blindText256Enc = make([]crypt.PixelPosition, 0, len(blindText)*256)
 	for c := 0; c < 256; c++ {
 		enc, _ := cipher.Encrypt(blindText)
 		blindText256Enc = append(blindText256Enc, enc...)
@@ -63,6 +70,90 @@ func TestLoad(t *testing.T) {
 	assert.Equal(t, a.Total, sum)
 }
 
+func TestAnalyse_AddMerge(t *testing.T) {
+	half := len(blindText256Enc) / 2
+
+	a := &Analyse{}
+	a.Add(blindText256Enc[:half])
+	a.Add(blindText256Enc[half:])
+	assert.Equal(t, Load(blindText256Enc), a)
+
+	b := Load(blindText256Enc[:half])
+	b.Merge(Load(blindText256Enc[half:]))
+	assert.Equal(t, a, b)
+
+	// Merging into an empty analysis
+	c := &Analyse{}
+	c.Merge(a)
+	assert.Equal(t, a, c)
+	assert.Equal(t, cipher.Image.Dimension, c.ExpectedDimension)
+}
+
+func TestLoadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "analyze")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var names []string
+	var all []crypt.PixelPosition
+	for i := 0; i < 10; i++ {
+		enc, err := cipher.Encrypt(blindText)
+		assert.NoError(t, err)
+		all = append(all, enc...)
+
+		var buf bytes.Buffer
+		if i%2 == 0 {
+			assert.NoError(t, crypt.Write(&buf, cipher.Header(), enc))
+		} else {
+			assert.NoError(t, crypt.WriteJSON(&buf, enc))
+		}
+
+		name := filepath.Join(dir, fmt.Sprintf("%d.enc", i))
+		assert.NoError(t, ioutil.WriteFile(name, buf.Bytes(), 0644))
+		names = append(names, name)
+	}
+
+	for _, workers := range []int{0, 1, 3} {
+		a, err := LoadFiles(names, nil, workers)
+		assert.NoError(t, err)
+		assert.Equal(t, Load(all), a)
+	}
+
+	_, err = LoadFiles(append(names, filepath.Join(dir, "missing")), nil, 2)
+	assert.Error(t, err)
+
+	// Ciphertexts are read using the opener
+	opened := 0
+	a, err := LoadFiles([]string{"-"}, func(name string) (io.ReadCloser, error) {
+		opened++
+		return ioutil.NopCloser(bytes.NewReader(mustRead(t, names[0]))), nil
+	}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, opened)
+	assert.Equal(t, len(blindText), a.Total)
+
+	// Authenticated ciphertexts are analyzed without the key
+	c, err := crypt.New(cipher.Image, crypt.WithAuthentication())
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	ew, err := c.NewEncryptWriter(&buf)
+	assert.NoError(t, err)
+	_, err = io.WriteString(ew, blindText)
+	assert.NoError(t, err)
+	assert.NoError(t, ew.Close())
+	a, err = LoadFiles([]string{"-"}, func(name string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, len(blindText), a.Total)
+}
+
+func mustRead(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile(name)
+	assert.NoError(t, err)
+	return b
+}
+
 func TestAnalysis_ExtractGroups(t *testing.T) {
 
 	// Build blindText set
@@ -74,8 +165,54 @@ func TestAnalysis_ExtractGroups(t *testing.T) {
 		blindTextSet[uint8(v)] = true
 	}
 
-	// a := Load(blindText256Enc)
-	// TODO
-	// groups := a.ExtractGroups()
-	// assert.LessOrEqual(t, len(blindTextSet), len(groups))
+	a := Load(blindText256Enc)
+	groups, err := a.ExtractGroups(len(blindTextSet))
+	assert.NoError(t, err)
+	assert.Len(t, groups, len(blindTextSet))
+
+	// Every position is assigned to exactly one group
+	positions := make(map[crypt.PixelPosition]bool)
+	total := 0
+	for _, g := range groups {
+		assert.NotEmpty(t, g.PixelPositions)
+		for _, p := range g.PixelPositions {
+			assert.False(t, positions[p])
+			positions[p] = true
+		}
+		total += g.Total
+	}
+	assert.Len(t, positions, len(a.Frequency))
+	assert.Equal(t, a.Total, total)
+
+	// Default scaling
+	groups, err = a.ExtractGroups(0)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, groups)
+}
+
+func TestSubstitute(t *testing.T) {
+	in := []crypt.PixelPosition{{Width: 1, Height: 1}, {Width: 2, Height: 2}, {Width: 1, Height: 1}, {Width: 3, Height: 3}}
+	groups := []PixelGroupFrequency{
+		{PixelPositions: []crypt.PixelPosition{{Width: 3, Height: 3}}, Total: 1},
+		{PixelPositions: []crypt.PixelPosition{{Width: 1, Height: 1}, {Width: 2, Height: 2}}, Total: 3},
+	}
+
+	s, err := Substitute(in, groups, "AB")
+	assert.NoError(t, err)
+	assert.Equal(t, "AAAB", s)
+
+	_, err = Substitute(in, groups, "A")
+	assert.Error(t, err)
+
+	_, err = Substitute(in, groups[1:], "AB")
+	assert.Error(t, err)
+
+	// Frame-wise substitution
+	sub, err := NewSubstitution(groups, "AB")
+	assert.NoError(t, err)
+	first, err := sub.Apply(in[:1])
+	assert.NoError(t, err)
+	rest, err := sub.Apply(in[1:])
+	assert.NoError(t, err)
+	assert.Equal(t, s, string(first)+string(rest))
 }
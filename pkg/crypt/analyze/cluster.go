@@ -1,5 +1,40 @@
 package crypt
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Clusterer partitions pixel position frequencies into clusters of positions expected to
+// represent the same plaintext symbol
+type Clusterer interface {
+	// Cluster partitions the frequencies, sorted ascending by count, into k clusters. Every
+	// position has to be part of exactly one cluster.
+	Cluster(sorted []PixelPositionFrequency, k int) ([][]PixelPositionFrequency, error)
+}
+
+// Clusterers contains the available clustering strategies by name
+var Clusterers = map[string]Clusterer{
+	"stdev":  StdevWalker{},
+	"kmeans": KMeans{},
+	"jenks":  Jenks{},
+	"gmm":    GMM{},
+}
+
+// NewClusterer returns the clustering strategy with the given name
+func NewClusterer(name string) (Clusterer, error) {
+	c, ok := Clusterers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown clusterer %q", name)
+	}
+	return c, nil
+}
+
+// errClusterCount is returned by clusterers requiring a positive number of clusters
+var errClusterCount = errors.New("number of clusters has to be positive")
+
 /* from math import sqrt
 
 def parse(lst, n):
@@ -18,3 +53,457 @@ def parse(lst, n):
     yield cluster           # yield the last cluster
 
 */
+
+// moments accumulates the values of a cluster
+type moments struct {
+	n          int
+	sum        int
+	sumSquared int
+}
+
+func (m *moments) add(v int) {
+	m.n++
+	m.sum += v
+	m.sumSquared += v * v
+}
+
+// stat returns mean & standard deviation
+func (m *moments) stat() (float64, float64) {
+	mean := float64(m.sum) / float64(m.n)
+	stdev := math.Sqrt(math.Max(0, (float64(m.sumSquared)/float64(m.n))-mean*mean))
+
+	return mean, stdev
+}
+
+func sum(in []PixelPositionFrequency) int {
+	sum := 0
+	for _, v := range in {
+		sum += v.Count
+	}
+	return sum
+}
+
+// walk clusters the sorted frequencies based on the standard deviation
+func walk(in []PixelPositionFrequency, minStdev float64) [][]PixelPositionFrequency {
+	var clusters [][]PixelPositionFrequency
+
+	var cluster []PixelPositionFrequency
+	var m moments
+
+	// Iterate sorted PixelPositions based on their count
+	for _, v := range in {
+
+		// The first value starts a cluster
+		if len(cluster) == 0 {
+			cluster = append(cluster, v)
+			m.add(v.Count)
+			continue
+		}
+
+		// Calculate mean&stdev of cluster, a single value is compared using the minimum
+		mean, stdev := m.stat()
+		if stdev < minStdev {
+			stdev = minStdev
+		}
+
+		if math.Abs(mean-float64(v.Count)) > stdev {
+			clusters = append(clusters, cluster)
+			cluster = []PixelPositionFrequency{v}
+			m = moments{}
+		} else {
+			cluster = append(cluster, v)
+		}
+		m.add(v.Count)
+	}
+
+	// Last cluster
+	if len(cluster) > 0 {
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters
+}
+
+// StdevWalker walks the sorted frequencies and starts a new cluster whenever a value
+// deviates from the current cluster mean by more than its standard deviation
+type StdevWalker struct {
+	// Scale of the minimum standard deviation relative to the number of positions, it
+	// is only used if no number of clusters is requested. Defaults to 0.0025.
+	Scale float64
+}
+
+// Cluster into exactly k clusters, fewer if there are less than k distinct frequencies, by
+// searching the minimum standard deviation; for k <= 0 the minimum standard deviation is scaled
+func (s StdevWalker) Cluster(sorted []PixelPositionFrequency, k int) ([][]PixelPositionFrequency, error) {
+	if len(sorted) == 0 {
+		return nil, nil
+	}
+
+	if k <= 0 {
+		scale := s.Scale
+		if scale == 0 {
+			scale = 0.0025
+		}
+		return walk(sorted, float64(len(sorted))*scale), nil
+	}
+
+	// The number of clusters decreases with the minimum standard deviation ->
+	// search for the largest one still resulting in at least k clusters
+	lo, hi := 0.0, float64(sorted[len(sorted)-1].Count)
+	clusters := walk(sorted, lo)
+	for i := 0; i < 64; i++ {
+		mid := (lo + hi) / 2
+		c := walk(sorted, mid)
+		if len(c) >= k {
+			lo, clusters = mid, c
+		} else {
+			hi = mid
+		}
+	}
+
+	return mergeClosest(clusters, k), nil
+}
+
+// mergeClosest merges the adjacent clusters with the closest means until k clusters remain
+func mergeClosest(clusters [][]PixelPositionFrequency, k int) [][]PixelPositionFrequency {
+	mean := func(c []PixelPositionFrequency) float64 {
+		return float64(sum(c)) / float64(len(c))
+	}
+	for len(clusters) > k {
+		best := 0
+		for i := 1; i < len(clusters)-1; i++ {
+			if mean(clusters[i+1])-mean(clusters[i]) < mean(clusters[best+1])-mean(clusters[best]) {
+				best = i
+			}
+		}
+		merged := append(append([]PixelPositionFrequency{}, clusters[best]...), clusters[best+1]...)
+		clusters = append(clusters[:best+1], clusters[best+2:]...)
+		clusters[best] = merged
+	}
+	return clusters
+}
+
+// level contains all positions sharing the same frequency, sorted[from:to]
+type level struct {
+	value    float64
+	weight   float64
+	from, to int
+}
+
+// levels merges positions with identical frequencies, the clusterers operate on the
+// (much fewer) distinct frequencies weighted by their number of positions
+func levels(sorted []PixelPositionFrequency) []level {
+	var out []level
+	for i, v := range sorted {
+		if len(out) > 0 && out[len(out)-1].value == float64(v.Count) {
+			out[len(out)-1].weight++
+			out[len(out)-1].to = i + 1
+			continue
+		}
+		out = append(out, level{value: float64(v.Count), weight: 1, from: i, to: i + 1})
+	}
+	return out
+}
+
+// split returns the clusters of contiguous levels, breaks contains the first level of every cluster but the first
+func split(sorted []PixelPositionFrequency, lv []level, breaks []int) [][]PixelPositionFrequency {
+	var clusters [][]PixelPositionFrequency
+	from := 0
+	for _, b := range append(breaks, len(lv)) {
+		clusters = append(clusters, sorted[lv[from].from:lv[b-1].to])
+		from = b
+	}
+	return clusters
+}
+
+// prefixSums allows computing the within-cluster sum of squares of contiguous levels in O(1)
+type prefixSums struct {
+	w, s, s2 []float64
+}
+
+func newPrefixSums(lv []level) *prefixSums {
+	p := &prefixSums{
+		w:  make([]float64, len(lv)+1),
+		s:  make([]float64, len(lv)+1),
+		s2: make([]float64, len(lv)+1),
+	}
+	for i, l := range lv {
+		p.w[i+1] = p.w[i] + l.weight
+		p.s[i+1] = p.s[i] + l.weight*l.value
+		p.s2[i+1] = p.s2[i] + l.weight*l.value*l.value
+	}
+	return p
+}
+
+// cost returns the sum of squared deviations from the mean of the levels [i, j)
+func (p *prefixSums) cost(i, j int) float64 {
+	w := p.w[j] - p.w[i]
+	if w == 0 {
+		return 0
+	}
+	s := p.s[j] - p.s[i]
+	return math.Max(0, p.s2[j]-p.s2[i]-s*s/w)
+}
+
+// KMeans computes the optimal 1D k-means clustering (minimum within-cluster sum of
+// squares) using dynamic programming with divide & conquer optimization, like the
+// kmeans1d package used by the Python attack scripts
+type KMeans struct{}
+
+// Cluster into exactly k clusters, fewer if there are less than k distinct frequencies
+func (KMeans) Cluster(sorted []PixelPositionFrequency, k int) ([][]PixelPositionFrequency, error) {
+	if k <= 0 {
+		return nil, errClusterCount
+	}
+	if len(sorted) == 0 {
+		return nil, nil
+	}
+
+	lv := levels(sorted)
+	m := len(lv)
+	if k > m {
+		k = m
+	}
+	p := newPrefixSums(lv)
+
+	// prev[j] contains the minimum cost of clustering the levels [0, j)
+	prev := make([]float64, m+1)
+	for j := 1; j <= m; j++ {
+		prev[j] = p.cost(0, j)
+	}
+
+	// arg[c][j] contains the first level of the last cluster for c+1 clusters of [0, j)
+	arg := make([][]int, k)
+	for c := 1; c < k; c++ {
+		cur := make([]float64, m+1)
+		arg[c] = make([]int, m+1)
+		for j := range cur {
+			cur[j] = math.Inf(1)
+		}
+
+		// The optimal split point is monotone in j
+		var compute func(lo, hi, optLo, optHi int)
+		compute = func(lo, hi, optLo, optHi int) {
+			if lo > hi {
+				return
+			}
+			mid := (lo + hi) / 2
+			best, bestI := math.Inf(1), optLo
+			for i := optLo; i <= optHi && i < mid; i++ {
+				if v := prev[i] + p.cost(i, mid); v < best {
+					best, bestI = v, i
+				}
+			}
+			cur[mid], arg[c][mid] = best, bestI
+			compute(lo, mid-1, optLo, bestI)
+			compute(mid+1, hi, bestI, optHi)
+		}
+		compute(c+1, m, c, m-1)
+
+		prev = cur
+	}
+
+	// Backtrack the cluster boundaries
+	breaks := make([]int, k-1)
+	j := m
+	for c := k - 1; c > 0; c-- {
+		j = arg[c][j]
+		breaks[c-1] = j
+	}
+
+	return split(sorted, lv, breaks), nil
+}
+
+// Jenks computes the natural breaks of the frequencies using Fisher's exact algorithm
+// as formulated by Jenks, minimizing the within-class variance
+type Jenks struct{}
+
+// Cluster into exactly k classes, fewer if there are less than k distinct frequencies
+func (Jenks) Cluster(sorted []PixelPositionFrequency, k int) ([][]PixelPositionFrequency, error) {
+	if k <= 0 {
+		return nil, errClusterCount
+	}
+	if len(sorted) == 0 {
+		return nil, nil
+	}
+
+	lv := levels(sorted)
+	m := len(lv)
+	if k > m {
+		k = m
+	}
+
+	// lower[l][j] contains the first level (1-based) of class j when clustering the levels
+	// 1..l into j classes, variance[l][j] the corresponding sum of squared deviations
+	lower := make([][]int, m+1)
+	variance := make([][]float64, m+1)
+	for l := range lower {
+		lower[l] = make([]int, k+1)
+		variance[l] = make([]float64, k+1)
+		for j := 1; j <= k; j++ {
+			lower[l][j] = 1
+			if l > 1 {
+				variance[l][j] = math.Inf(1)
+			}
+		}
+	}
+
+	for l := 2; l <= m; l++ {
+		var s1, s2, w, v float64
+		for n := 1; n <= l; n++ {
+			// Class spanning the levels lo..l
+			lo := l - n + 1
+			val, weight := lv[lo-1].value, lv[lo-1].weight
+			s1 += weight * val
+			s2 += weight * val * val
+			w += weight
+			v = s2 - s1*s1/w
+
+			if lo == 1 {
+				continue
+			}
+			for j := 2; j <= k; j++ {
+				if c := v + variance[lo-1][j-1]; c <= variance[l][j] {
+					lower[l][j] = lo
+					variance[l][j] = c
+				}
+			}
+		}
+		lower[l][1] = 1
+		variance[l][1] = v
+	}
+
+	// Backtrack the class boundaries
+	breaks := make([]int, k-1)
+	l := m
+	for j := k; j > 1; j-- {
+		lo := lower[l][j]
+		breaks[j-2] = lo - 1
+		l = lo - 1
+	}
+
+	return split(sorted, lv, breaks), nil
+}
+
+// GMM fits a Gaussian mixture model to the frequencies using expectation maximization,
+// initialized with the k-means clustering. Positions are assigned to the component with
+// the highest posterior probability.
+type GMM struct {
+	// Iterations limits the number of EM iterations, defaults to 200
+	Iterations int
+}
+
+// Cluster into at most k clusters, components without any assigned position are dropped
+func (g GMM) Cluster(sorted []PixelPositionFrequency, k int) ([][]PixelPositionFrequency, error) {
+	initial, err := KMeans{}.Cluster(sorted, k)
+	if err != nil || len(initial) == 0 {
+		return initial, err
+	}
+
+	iterations := g.Iterations
+	if iterations <= 0 {
+		iterations = 200
+	}
+
+	lv := levels(sorted)
+	k = len(initial)
+
+	// Initialize the components with the k-means clusters
+	mean := make([]float64, k)
+	vari := make([]float64, k)
+	weight := make([]float64, k)
+	for c, cl := range initial {
+		var m moments
+		for _, v := range cl {
+			m.add(v.Count)
+		}
+		mu, sd := m.stat()
+		mean[c], vari[c], weight[c] = mu, sd*sd, float64(len(cl))/float64(len(sorted))
+	}
+
+	// Counts are integers, avoid collapsing components
+	const minVariance = 0.25
+
+	resp := make([][]float64, len(lv))
+	for i := range resp {
+		resp[i] = make([]float64, k)
+	}
+
+	lastLikelihood := math.Inf(-1)
+	for it := 0; it < iterations; it++ {
+		// Expectation
+		likelihood := 0.0
+		for i, l := range lv {
+			maxLog := math.Inf(-1)
+			for c := 0; c < k; c++ {
+				v := math.Max(vari[c], minVariance)
+				d := l.value - mean[c]
+				resp[i][c] = math.Log(weight[c]) - 0.5*math.Log(2*math.Pi*v) - d*d/(2*v)
+				maxLog = math.Max(maxLog, resp[i][c])
+			}
+			var sum float64
+			for c := 0; c < k; c++ {
+				resp[i][c] = math.Exp(resp[i][c] - maxLog)
+				sum += resp[i][c]
+			}
+			for c := 0; c < k; c++ {
+				resp[i][c] /= sum
+			}
+			likelihood += l.weight * (maxLog + math.Log(sum))
+		}
+
+		// Maximization
+		total := float64(len(sorted))
+		for c := 0; c < k; c++ {
+			var w, s, s2 float64
+			for i, l := range lv {
+				r := resp[i][c] * l.weight
+				w += r
+				s += r * l.value
+				s2 += r * l.value * l.value
+			}
+			if w < 1e-12 {
+				weight[c] = 1e-12
+				continue
+			}
+			weight[c] = w / total
+			mean[c] = s / w
+			vari[c] = math.Max(s2/w-mean[c]*mean[c], minVariance)
+		}
+
+		if math.Abs(likelihood-lastLikelihood) < 1e-9*math.Abs(likelihood) {
+			break
+		}
+		lastLikelihood = likelihood
+	}
+
+	// Assign every level to its most likely component
+	clusters := make([][]PixelPositionFrequency, k)
+	for i, l := range lv {
+		best := 0
+		for c := 1; c < k; c++ {
+			if resp[i][c] > resp[i][best] {
+				best = c
+			}
+		}
+		clusters[best] = append(clusters[best], sorted[l.from:l.to]...)
+	}
+
+	// Drop empty components, order by mean frequency
+	order := make([]int, 0, k)
+	for c := range clusters {
+		if len(clusters[c]) > 0 {
+			order = append(order, c)
+		}
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return mean[order[i]] < mean[order[j]]
+	})
+
+	out := make([][]PixelPositionFrequency, len(order))
+	for i, c := range order {
+		out[i] = clusters[c]
+	}
+
+	return out, nil
+}
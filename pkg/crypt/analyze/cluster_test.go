@@ -0,0 +1,151 @@
+package crypt
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+)
+
+// syntheticFrequencies returns sorted frequencies of well separated groups, the group of
+// every position is encoded in its height
+func syntheticFrequencies(centers []int, size int) []PixelPositionFrequency {
+	r := rand.New(rand.NewSource(1))
+
+	var out []PixelPositionFrequency
+	for g, c := range centers {
+		for w := 0; w < size; w++ {
+			out = append(out, PixelPositionFrequency{
+				PixelPosition: crypt.PixelPosition{Width: w, Height: g},
+				Count:         c + r.Intn(5) - 2,
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Count < out[j].Count
+	})
+	return out
+}
+
+// sse returns the within-cluster sum of squares
+func sse(clusters [][]PixelPositionFrequency) float64 {
+	var out float64
+	for _, c := range clusters {
+		var m moments
+		for _, v := range c {
+			m.add(v.Count)
+		}
+		_, sd := m.stat()
+		out += sd * sd * float64(m.n)
+	}
+	return out
+}
+
+func TestClusterers_Separated(t *testing.T) {
+	centers := []int{10, 40, 80, 150, 300}
+	sorted := syntheticFrequencies(centers, 50)
+
+	for name, c := range Clusterers {
+		clusters, err := c.Cluster(sorted, len(centers))
+		assert.NoError(t, err, name)
+		assert.Len(t, clusters, len(centers), name)
+
+		total := 0
+		for _, cl := range clusters {
+			// All positions of a cluster belong to the same group
+			for _, v := range cl {
+				assert.Equal(t, cl[0].PixelPosition.Height, v.PixelPosition.Height, name)
+			}
+			total += len(cl)
+		}
+		assert.Equal(t, len(sorted), total, name)
+	}
+}
+
+func TestKMeans_Optimal(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	for n := 0; n < 20; n++ {
+		var sorted []PixelPositionFrequency
+		for i := 0; i < 200; i++ {
+			sorted = append(sorted, PixelPositionFrequency{Count: r.Intn(100)})
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Count < sorted[j].Count
+		})
+
+		for _, k := range []int{1, 2, 5, 13} {
+			km, err := KMeans{}.Cluster(sorted, k)
+			assert.NoError(t, err)
+			assert.Len(t, km, k)
+			jenks, err := Jenks{}.Cluster(sorted, k)
+			assert.NoError(t, err)
+			assert.Len(t, jenks, k)
+
+			// Both compute the exact optimum
+			assert.InDelta(t, sse(jenks), sse(km), 1e-6)
+			// ... which is at least as good as the greedy walker
+			walker, err := StdevWalker{}.Cluster(sorted, k)
+			assert.NoError(t, err)
+			assert.Len(t, walker, k)
+			assert.LessOrEqual(t, sse(km), sse(walker)+1e-6)
+		}
+	}
+}
+
+func TestStdevWalker_DistinctFrequencies(t *testing.T) {
+	// Every widely spaced frequency forms a cluster of its own
+	sorted := syntheticFrequencies([]int{1, 100, 200}, 1)
+	clusters, err := StdevWalker{}.Cluster(sorted, 3)
+	assert.NoError(t, err)
+	assert.Len(t, clusters, 3)
+
+	sorted = syntheticFrequencies([]int{1, 100, 200, 300, 400}, 4)
+	clusters, err = StdevWalker{}.Cluster(sorted, 5)
+	assert.NoError(t, err)
+	assert.Len(t, clusters, 5)
+	for _, c := range clusters {
+		assert.Len(t, c, 4)
+	}
+}
+
+func TestClusterers_Degenerate(t *testing.T) {
+	sorted := syntheticFrequencies([]int{10}, 3)
+
+	for name, c := range Clusterers {
+		// More clusters requested than distinct values
+		clusters, err := c.Cluster(sorted, 10)
+		assert.NoError(t, err, name)
+		total := 0
+		for _, cl := range clusters {
+			total += len(cl)
+		}
+		assert.Equal(t, 3, total, name)
+
+		clusters, err = c.Cluster(nil, 3)
+		assert.NoError(t, err, name)
+		assert.Empty(t, clusters, name)
+	}
+
+	_, err := KMeans{}.Cluster(sorted, 0)
+	assert.Error(t, err)
+
+	_, err = NewClusterer("magic")
+	assert.Error(t, err)
+}
+
+func TestAnalysis_ExtractGroupsWith(t *testing.T) {
+	a := Load(blindText256Enc)
+	for name, c := range Clusterers {
+		groups, err := a.ExtractGroupsWith(c, 40)
+		assert.NoError(t, err, name)
+
+		total := 0
+		for _, g := range groups {
+			total += g.Total
+		}
+		assert.Equal(t, a.Total, total, name)
+	}
+}
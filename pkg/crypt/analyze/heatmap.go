@@ -0,0 +1,140 @@
+package crypt
+
+import (
+	"fmt"
+	gi "image"
+	"image/color"
+	"math"
+
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+// HeatmapOptions configures the rendering of a heatmap
+type HeatmapOptions struct {
+	// Dimension of the key, ExpectedDimension is used if empty
+	Dimension image.Dimension
+	// Groups are drawn in distinct colors, brightness still encodes the frequency
+	Groups []PixelGroupFrequency
+	// Key is drawn to the right of the heatmap, showing the symbol of every pixel
+	Key  *image.Image
+	Mask uint8
+	// Scale is the edge length of a key pixel in the rendering
+	Scale int
+}
+
+// Heatmap renders the position frequencies over the key dimension: unused positions are
+// black, frequent positions bright. Frequencies are scaled logarithmically. The rendering is
+// limited to MaxKeyPixels, the inferred dimension stems from untrusted positions.
+func (a *Analyse) Heatmap(opts HeatmapOptions) (gi.Image, error) {
+	dim := opts.Dimension
+	if dim.Width == 0 || dim.Height == 0 {
+		dim = a.ExpectedDimension
+	}
+	scale := opts.Scale
+	if scale < 1 {
+		scale = 1
+	}
+
+	width := dim.Width
+	if opts.Key != nil {
+		// Separated by a single column
+		width = 2*dim.Width + 1
+	}
+	pixels := uint64(width) * uint64(dim.Height)
+	if uint64(scale) > MaxKeyPixels || pixels*uint64(scale)*uint64(scale) > MaxKeyPixels {
+		return nil, fmt.Errorf("heatmap of %dx%d positions scaled by %d exceeds %d pixels", width, dim.Height, scale, MaxKeyPixels)
+	}
+	out := gi.NewRGBA(gi.Rect(0, 0, width*scale, dim.Height*scale))
+	fill := func(x, y int, c color.Color) {
+		for dy := 0; dy < scale; dy++ {
+			for dx := 0; dx < scale; dx++ {
+				out.Set(x*scale+dx, y*scale+dy, c)
+			}
+		}
+	}
+
+	// Background of the separator and unused positions
+	for y := 0; y < dim.Height; y++ {
+		for x := 0; x < width; x++ {
+			fill(x, y, color.Black)
+		}
+	}
+
+	maxCount := 0
+	for _, v := range a.Frequency {
+		if v > maxCount {
+			maxCount = v
+		}
+	}
+
+	group := make(map[crypt.PixelPosition]int)
+	for g, v := range opts.Groups {
+		for _, p := range v.PixelPositions {
+			group[p] = g
+		}
+	}
+
+	for p, v := range a.Frequency {
+		if p.Width >= dim.Width || p.Height >= dim.Height {
+			continue
+		}
+		intensity := math.Log1p(float64(v)) / math.Log1p(float64(maxCount))
+
+		if g, ok := group[p]; ok {
+			fill(p.Width, p.Height, groupColor(g, intensity))
+		} else {
+			fill(p.Width, p.Height, heatColor(intensity))
+		}
+	}
+
+	if opts.Key != nil {
+		mask := opts.Mask
+		if mask == 0 {
+			mask = image.MaskASCII
+		}
+		k := opts.Key
+		for y := 0; y < dim.Height && y < k.Dimension.Height; y++ {
+			for x := 0; x < dim.Width && x < k.Dimension.Width; x++ {
+				v := k.Data[x+k.Dimension.Width*y] & mask
+				fill(dim.Width+1+x, y, color.Gray{Y: uint8(int(v) * 255 / int(mask))})
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// heatColor maps an intensity in [0, 1] onto black, red, yellow and white
+func heatColor(v float64) color.RGBA {
+	c := func(x float64) uint8 {
+		return uint8(255 * math.Max(0, math.Min(1, x)))
+	}
+	return color.RGBA{R: c(3 * v), G: c(3*v - 1), B: c(3*v - 2), A: 0xff}
+}
+
+// groupColor returns a distinct hue per group, the golden angle keeps neighbouring groups apart
+func groupColor(g int, v float64) color.RGBA {
+	hue := math.Mod(float64(g)*137.508, 360) / 60
+	x := 1 - math.Abs(math.Mod(hue, 2)-1)
+
+	var r, gr, b float64
+	switch int(hue) {
+	case 0:
+		r, gr = 1, x
+	case 1:
+		r, gr = x, 1
+	case 2:
+		gr, b = 1, x
+	case 3:
+		gr, b = x, 1
+	case 4:
+		r, b = x, 1
+	default:
+		r, b = 1, x
+	}
+
+	// Keep rare positions visible
+	v = 0.25 + 0.75*v
+	return color.RGBA{R: uint8(255 * r * v), G: uint8(255 * gr * v), B: uint8(255 * b * v), A: 0xff}
+}
@@ -0,0 +1,56 @@
+package crypt
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+func TestAnalyse_Heatmap(t *testing.T) {
+	p := func(w, h int) crypt.PixelPosition { return crypt.PixelPosition{Width: w, Height: h} }
+	a := Load([]crypt.PixelPosition{p(0, 0), p(0, 0), p(0, 0), p(2, 1)})
+
+	// Inferred dimension
+	img, err := a.Heatmap(HeatmapOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, img.Bounds().Dx())
+	assert.Equal(t, 2, img.Bounds().Dy())
+	assert.Equal(t, heatColor(1), img.At(0, 0))
+	assert.Equal(t, heatColor(0.5), img.At(2, 1))
+	assert.Equal(t, color.RGBA{A: 0xff}, img.At(1, 0))
+
+	// Scaled with groups and key
+	key := &image.Image{Data: []uint8{0x7f, 0, 0, 0, 0, 0, 0, 0}, Dimension: image.Dimension{Width: 4, Height: 2}}
+	img, err = a.Heatmap(HeatmapOptions{
+		Dimension: key.Dimension,
+		Groups:    []PixelGroupFrequency{{PixelPositions: []crypt.PixelPosition{p(0, 0)}}},
+		Key:       key,
+		Scale:     2,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2*9, img.Bounds().Dx())
+	assert.Equal(t, 2*2, img.Bounds().Dy())
+	assert.Equal(t, groupColor(0, 1), img.At(1, 1))
+	assert.Equal(t, heatColor(0.5), img.At(5, 3))
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, img.At(2*5, 0))
+
+	// The dimension inferred from a single crafted position is bounded
+	_, err = Load([]crypt.PixelPosition{p(1<<30, 0)}).Heatmap(HeatmapOptions{})
+	assert.Error(t, err)
+	_, err = a.Heatmap(HeatmapOptions{Scale: 1 << 40})
+	assert.Error(t, err)
+	_, err = a.Heatmap(HeatmapOptions{Scale: 1 << 12})
+	assert.Error(t, err)
+}
+
+func TestGroupColor(t *testing.T) {
+	seen := make(map[color.RGBA]bool)
+	for g := 0; g < 26; g++ {
+		c := groupColor(g, 1)
+		assert.False(t, seen[c])
+		seen[c] = true
+	}
+}
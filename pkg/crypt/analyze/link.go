@@ -0,0 +1,154 @@
+package crypt
+
+import (
+	"sort"
+
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+)
+
+// Linker recovers homophone groups from multiple encryptions of the same plaintext: all
+// pixel positions used at the same offset represent the same symbol. Positions are merged
+// using a union-find structure, memory is bounded by the number of distinct positions.
+type Linker struct {
+	// ids contains the union-find index of every position
+	ids    map[crypt.PixelPosition]int32
+	pos    []crypt.PixelPosition
+	parent []int32
+	count  []int
+}
+
+// NewLinker creates an empty Linker
+func NewLinker() *Linker {
+	return &Linker{ids: make(map[crypt.PixelPosition]int32)}
+}
+
+// id returns the union-find index of a position, unknown positions are added
+func (l *Linker) id(p crypt.PixelPosition) int32 {
+	if id, ok := l.ids[p]; ok {
+		return id
+	}
+
+	id := int32(len(l.parent))
+	l.ids[p] = id
+	l.pos = append(l.pos, p)
+	l.parent = append(l.parent, id)
+	l.count = append(l.count, 0)
+	return id
+}
+
+// find returns the root of a set, compressing the path
+func (l *Linker) find(id int32) int32 {
+	root := id
+	for l.parent[root] != root {
+		root = l.parent[root]
+	}
+	for l.parent[id] != root {
+		l.parent[id], id = root, l.parent[id]
+	}
+	return root
+}
+
+func (l *Linker) union(a, b int32) {
+	ra, rb := l.find(a), l.find(b)
+	if ra != rb {
+		l.parent[rb] = ra
+	}
+}
+
+// Link merges the positions at the same offset of aligned ciphertext chunks; chunks have
+// to start at the same plaintext offset, positions beyond the shortest chunk are ignored
+func (l *Linker) Link(aligned ...[]crypt.PixelPosition) {
+	n := -1
+	for _, c := range aligned {
+		if n < 0 || len(c) < n {
+			n = len(c)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		first := l.id(aligned[0][i])
+		l.count[first]++
+		for _, c := range aligned[1:] {
+			id := l.id(c[i])
+			l.count[id]++
+			l.union(first, id)
+		}
+	}
+}
+
+// Groups returns the linked homophone groups ordered by descending frequency
+func (l *Linker) Groups() []PixelGroupFrequency {
+	index := make(map[int32]int)
+	var groups []PixelGroupFrequency
+
+	for id := range l.parent {
+		root := l.find(int32(id))
+		g, ok := index[root]
+		if !ok {
+			g = len(groups)
+			index[root] = g
+			groups = append(groups, PixelGroupFrequency{})
+		}
+		groups[g].PixelPositions = append(groups[g].PixelPositions, l.pos[id])
+		groups[g].Total += l.count[id]
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Total > groups[j].Total
+	})
+
+	return groups
+}
+
+// Link contains the homophone groups recovered from aligned ciphertexts
+type Link struct {
+	// Groups ordered by descending frequency
+	Groups []PixelGroupFrequency
+	// Transcript contains the group index of every position of the first ciphertext
+	Transcript []int
+}
+
+// LinkAlignedCiphertexts recovers the homophone groups of multiple encryptions of the
+// same plaintext and transforms the first ciphertext into a substitution cipher
+func LinkAlignedCiphertexts(in [][]crypt.PixelPosition) *Link {
+	if len(in) == 0 {
+		return &Link{}
+	}
+
+	l := NewLinker()
+	l.Link(in...)
+
+	out := &Link{Groups: l.Groups()}
+
+	group := make(map[crypt.PixelPosition]int)
+	for g, v := range out.Groups {
+		for _, p := range v.PixelPositions {
+			group[p] = g
+		}
+	}
+
+	out.Transcript = make([]int, 0, len(in[0]))
+	for _, p := range in[0] {
+		g, ok := group[p]
+		if !ok {
+			break
+		}
+		out.Transcript = append(out.Transcript, g)
+	}
+
+	return out
+}
+
+// Substitute returns the transcript as substitution cipher, group i is replaced by alphabet[i]
+func (l *Link) Substitute(alphabet string) (string, error) {
+	return Substitute(l.transcriptPositions(), l.Groups, alphabet)
+}
+
+// transcriptPositions returns a representative position for every symbol of the transcript
+func (l *Link) transcriptPositions() []crypt.PixelPosition {
+	out := make([]crypt.PixelPosition, len(l.Transcript))
+	for i, g := range l.Transcript {
+		out[i] = l.Groups[g].PixelPositions[0]
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package crypt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+)
+
+func encryptMany(t testing.TB, plain string, n int) [][]crypt.PixelPosition {
+	out := make([][]crypt.PixelPosition, n)
+	for i := range out {
+		enc, err := cipher.Encrypt(plain)
+		assert.NoError(t, err)
+		out[i] = enc
+	}
+	return out
+}
+
+// symbol returns the plaintext symbol of a position
+func symbol(p crypt.PixelPosition) uint8 {
+	return cipher.Image.Data[p.Width+cipher.Image.Dimension.Width*p.Height] & cipher.Mask
+}
+
+func TestLinkAlignedCiphertexts(t *testing.T) {
+	l := LinkAlignedCiphertexts(encryptMany(t, blindText, 30))
+
+	symbols := make(map[uint8]bool)
+	for _, c := range []byte(blindText) {
+		symbols[c] = true
+	}
+	assert.GreaterOrEqual(t, len(l.Groups), len(symbols))
+
+	// Groups are pure
+	total := 0
+	for i, g := range l.Groups {
+		for _, p := range g.PixelPositions {
+			assert.Equal(t, symbol(g.PixelPositions[0]), symbol(p))
+		}
+		if i > 0 {
+			assert.LessOrEqual(t, g.Total, l.Groups[i-1].Total)
+		}
+		total += g.Total
+	}
+	assert.Equal(t, 30*len(blindText), total)
+
+	// Transcript is consistent with the plaintext
+	assert.Len(t, l.Transcript, len(blindText))
+	for i, g := range l.Transcript {
+		assert.Equal(t, blindText[i], symbol(l.Groups[g].PixelPositions[0]))
+	}
+
+	// Most frequent group is the most frequent plaintext symbol
+	assert.Equal(t, uint8(' '), symbol(l.Groups[0].PixelPositions[0]))
+}
+
+func TestLink_Substitute(t *testing.T) {
+	p := func(w int) crypt.PixelPosition { return crypt.PixelPosition{Width: w} }
+
+	// "abcab" encrypted twice, 'c' is the least frequent symbol
+	l := LinkAlignedCiphertexts([][]crypt.PixelPosition{
+		{p(1), p(2), p(3), p(4), p(2)},
+		{p(4), p(5), p(6), p(1), p(5)},
+	})
+	assert.Len(t, l.Groups, 3)
+
+	s, err := l.Substitute("XYZ")
+	assert.NoError(t, err)
+	assert.Equal(t, s[0], s[3])
+	assert.Equal(t, s[1], s[4])
+	assert.Equal(t, "Z", s[2:3])
+
+	_, err = l.Substitute("XY")
+	assert.Error(t, err)
+}
+
+func TestLinker_Unaligned(t *testing.T) {
+	enc := encryptMany(t, "hello world", 3)
+
+	// Positions beyond the shortest ciphertext are ignored
+	l := LinkAlignedCiphertexts([][]crypt.PixelPosition{enc[0], enc[1][:5], enc[2]})
+	assert.Len(t, l.Transcript, 5)
+
+	assert.Empty(t, LinkAlignedCiphertexts(nil).Groups)
+}
+
+func TestLinker_HugePositions(t *testing.T) {
+	// Untrusted coordinates do not size any table
+	far := crypt.PixelPosition{Width: 0, Height: 2000000000}
+	l := LinkAlignedCiphertexts([][]crypt.PixelPosition{
+		{far, {Width: -1, Height: -1}},
+		{{Width: 1 << 30}, {Width: -1, Height: -1}},
+	})
+	assert.Len(t, l.Groups, 2)
+}
+
+func BenchmarkLinker_1MByte(b *testing.B) {
+	plain := make([]byte, 1024*1024)
+	for i := range plain {
+		plain[i] = blindText[i%len(blindText)]
+	}
+	in := encryptMany(b, string(plain), 10)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		l := NewLinker()
+		l.Link(in...)
+		l.Groups()
+	}
+}
@@ -0,0 +1,178 @@
+package crypt
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+// Pair is a known plaintext and its ciphertext
+type Pair struct {
+	Plaintext  []byte
+	Ciphertext []crypt.PixelPosition
+	// Header of the ciphertext, nil for JSON ciphertexts
+	Header *crypt.Header
+}
+
+// MaxKeyPixels bounds the size of a recovered key image, the dimension of JSON ciphertexts is
+// inferred from untrusted positions
+const MaxKeyPixels = 1 << 26
+
+// SymbolCoverage describes how much of the homophone group of a symbol has been recovered
+type SymbolCoverage struct {
+	Symbol uint8 `json:"symbol"`
+	// Occurrences of the symbol in the plaintexts
+	Occurrences int `json:"occurrences"`
+	// Recovered distinct pixels of the group
+	Recovered int `json:"recovered"`
+	// Estimated size of the group
+	Estimated float64 `json:"estimated"`
+	// Coverage is the estimated fraction of the group which has been recovered
+	Coverage float64 `json:"coverage"`
+}
+
+// RecoveredKey is a partial key image reconstructed from known plaintexts
+type RecoveredKey struct {
+	// Image contains the symbol of every recovered pixel, unknown pixels are 0
+	Image *image.Image `json:"-"`
+	// Known marks the recovered pixels with 0xff
+	Known *image.Image `json:"-"`
+	Mask  uint8        `json:"mask"`
+
+	Dimension image.Dimension `json:"dimension"`
+	Recovered int             `json:"recovered"`
+	// Coverage is the recovered fraction of the key pixels
+	Coverage float64          `json:"coverage"`
+	Symbols  []SymbolCoverage `json:"symbols"`
+}
+
+// RecoverKey reconstructs the key image from known plaintext/ciphertext pairs: every used
+// position reveals its pixel value under the mask. The key dimension and mask are taken
+// from the ciphertext headers, for JSON ciphertexts the dimension is inferred from the
+// positions and the 7-bit ASCII mask is assumed.
+func RecoverKey(pairs []Pair) (*RecoveredKey, error) {
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("no known plaintext given")
+	}
+
+	var header *crypt.Header
+	extent := crypt.PixelPosition{Width: -1, Height: -1}
+	for i, p := range pairs {
+		if len(p.Plaintext) != len(p.Ciphertext) {
+			return nil, fmt.Errorf("pair %d: plaintext has %d bytes, ciphertext %d positions", i, len(p.Plaintext), len(p.Ciphertext))
+		}
+		if p.Header != nil {
+			if p.Header.Nonce != nil {
+				return nil, fmt.Errorf("pair %d: positions are permuted using a nonce", i)
+			}
+			if p.Header.Chained {
+				return nil, fmt.Errorf("pair %d: symbols are chained", i)
+			}
+			if header != nil && (header.Dimension != p.Header.Dimension || header.Mask != p.Header.Mask) {
+				return nil, fmt.Errorf("pair %d: ciphertext was encrypted using a different key", i)
+			}
+			header = p.Header
+		}
+		for _, pos := range p.Ciphertext {
+			extent = expand(extent, pos)
+		}
+	}
+
+	r := &RecoveredKey{
+		Mask:      image.MaskASCII,
+		Dimension: image.Dimension{Width: extent.Width + 1, Height: extent.Height + 1},
+	}
+	if header != nil {
+		r.Mask = header.Mask
+		r.Dimension = header.Dimension
+	}
+
+	if uint64(r.Dimension.Width)*uint64(r.Dimension.Height) > MaxKeyPixels {
+		return nil, fmt.Errorf("key dimension %dx%d exceeds %d pixels", r.Dimension.Width, r.Dimension.Height, MaxKeyPixels)
+	}
+	size := r.Dimension.Width * r.Dimension.Height
+	r.Image = &image.Image{Data: make([]uint8, size), Dimension: r.Dimension}
+	r.Known = &image.Image{Data: make([]uint8, size), Dimension: r.Dimension}
+
+	var occurrences, recovered [256]int
+	for i, p := range pairs {
+		for j, pos := range p.Ciphertext {
+			if pos.Width < 0 || pos.Height < 0 || pos.Width >= r.Dimension.Width || pos.Height >= r.Dimension.Height {
+				return nil, fmt.Errorf("pair %d: position %dx%d is outside of the key", i, pos.Width, pos.Height)
+			}
+
+			c := p.Plaintext[j]
+			if c&r.Mask != c {
+				return nil, fmt.Errorf("pair %d: plaintext byte 0x%02x at offset %d can not be represented", i, c, j)
+			}
+			occurrences[c]++
+
+			idx := pos.Width + r.Dimension.Width*pos.Height
+			if r.Known.Data[idx] == 0 {
+				r.Image.Data[idx] = c
+				r.Known.Data[idx] = 0xff
+				recovered[c]++
+				r.Recovered++
+			} else if r.Image.Data[idx] != c {
+				return nil, fmt.Errorf("pair %d: position %dx%d encodes both 0x%02x and 0x%02x", i, pos.Width, pos.Height, r.Image.Data[idx], c)
+			}
+		}
+	}
+
+	if size > 0 {
+		r.Coverage = float64(r.Recovered) / float64(size)
+	}
+
+	// A key is expected to distribute the pixels evenly across the symbols
+	prior := float64(size) / float64(int(r.Mask)+1)
+	for c := range occurrences {
+		if occurrences[c] == 0 {
+			continue
+		}
+		estimated := estimateGroupSize(occurrences[c], recovered[c], prior)
+		r.Symbols = append(r.Symbols, SymbolCoverage{
+			Symbol:      uint8(c),
+			Occurrences: occurrences[c],
+			Recovered:   recovered[c],
+			Estimated:   estimated,
+			Coverage:    float64(recovered[c]) / estimated,
+		})
+	}
+	sort.SliceStable(r.Symbols, func(i, j int) bool {
+		return r.Symbols[i].Symbol < r.Symbols[j].Symbol
+	})
+
+	return r, nil
+}
+
+// estimateGroupSize estimates the number of pixels of a symbol after drawing n pixels uniformly
+// with d distinct ones by solving d = g * (1 - (1 - 1/g)^n) for g. Without repetitions the group
+// size can not be estimated and the prior is used instead.
+func estimateGroupSize(n, d int, prior float64) float64 {
+	if d >= n {
+		return math.Max(prior, float64(d))
+	}
+
+	expected := func(g float64) float64 {
+		return g * (1 - math.Pow(1-1/g, float64(n)))
+	}
+
+	// expected is monotonically increasing in g
+	lo, hi := float64(d), float64(d)
+	for expected(hi) < float64(d) {
+		hi *= 2
+	}
+	for i := 0; i < 64; i++ {
+		mid := (lo + hi) / 2
+		if expected(mid) < float64(d) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return hi
+}
@@ -0,0 +1,99 @@
+package crypt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+)
+
+func TestRecoverKey(t *testing.T) {
+	var pairs []Pair
+	for _, enc := range encryptMany(t, blindText, 5) {
+		pairs = append(pairs, Pair{
+			Plaintext:  []byte(blindText),
+			Ciphertext: enc,
+			Header:     cipher.Header(),
+		})
+	}
+
+	r, err := RecoverKey(pairs)
+	assert.NoError(t, err)
+	assert.Equal(t, cipher.Image.Dimension, r.Dimension)
+	assert.Equal(t, cipher.Mask, r.Mask)
+
+	// Every recovered pixel matches the key
+	known := 0
+	for i, k := range r.Known.Data {
+		if k != 0 {
+			known++
+			assert.Equal(t, cipher.Image.Data[i]&cipher.Mask, r.Image.Data[i])
+		}
+	}
+	assert.Equal(t, known, r.Recovered)
+	assert.InDelta(t, float64(known)/float64(len(cipher.Image.Data)), r.Coverage, 1e-9)
+
+	// Group size estimates are close to the actual size for frequent symbols
+	for _, s := range r.Symbols {
+		assert.LessOrEqual(t, s.Coverage, 1.0)
+		if s.Symbol == ' ' {
+			actual := 0
+			for _, v := range cipher.Image.Data {
+				if v&cipher.Mask == ' ' {
+					actual++
+				}
+			}
+			assert.Equal(t, actual, s.Recovered)
+			assert.InDelta(t, 1.0, s.Coverage, 0.05)
+		}
+	}
+}
+
+func TestRecoverKey_Invalid(t *testing.T) {
+	p := func(w int) crypt.PixelPosition { return crypt.PixelPosition{Width: w} }
+
+	_, err := RecoverKey(nil)
+	assert.Error(t, err)
+
+	h := cipher.Header()
+	h.Nonce = make([]byte, crypt.NonceSize)
+	_, err = RecoverKey([]Pair{{Plaintext: []byte("a"), Ciphertext: []crypt.PixelPosition{p(0)}, Header: h}})
+	assert.Error(t, err)
+
+	h = cipher.Header()
+	h.Chained = true
+	_, err = RecoverKey([]Pair{{Plaintext: []byte("a"), Ciphertext: []crypt.PixelPosition{p(0)}, Header: h}})
+	assert.Error(t, err)
+
+	_, err = RecoverKey([]Pair{{Plaintext: []byte("ab"), Ciphertext: []crypt.PixelPosition{p(0)}}})
+	assert.Error(t, err)
+
+	// Conflicting symbols on the same position
+	_, err = RecoverKey([]Pair{{Plaintext: []byte("ab"), Ciphertext: []crypt.PixelPosition{p(0), p(0)}}})
+	assert.Error(t, err)
+
+	// Inferred dimensions are bounded
+	_, err = RecoverKey([]Pair{{Plaintext: []byte("a"), Ciphertext: []crypt.PixelPosition{{Width: 1 << 20, Height: 2000000000}}}})
+	assert.Error(t, err)
+	h = cipher.Header()
+	h.Dimension.Width, h.Dimension.Height = 1<<31, 1<<31
+	_, err = RecoverKey([]Pair{{Plaintext: []byte("a"), Ciphertext: []crypt.PixelPosition{p(0)}, Header: h}})
+	assert.Error(t, err)
+
+	// Dimension is inferred without header
+	r, err := RecoverKey([]Pair{{Plaintext: []byte("aba"), Ciphertext: []crypt.PixelPosition{p(0), p(3), p(0)}}})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, r.Dimension.Width)
+	assert.Equal(t, 2, r.Recovered)
+	assert.Equal(t, []uint8{'a', 0, 0, 'b'}, r.Image.Data)
+}
+
+func TestEstimateGroupSize(t *testing.T) {
+	// No repetitions
+	assert.Equal(t, 10.0, estimateGroupSize(3, 3, 10))
+	assert.Equal(t, 3.0, estimateGroupSize(3, 3, 1))
+
+	// Many draws cover the whole group
+	assert.InDelta(t, 50.0, estimateGroupSize(10000, 50, 1), 0.5)
+	assert.InDelta(t, 100.0, estimateGroupSize(100, 64, 1), 3)
+}
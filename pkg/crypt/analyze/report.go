@@ -0,0 +1,99 @@
+package crypt
+
+import (
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+// HistogramBin counts the positions used between From and To times (inclusive)
+type HistogramBin struct {
+	From      int `json:"from"`
+	To        int `json:"to"`
+	Positions int `json:"positions"`
+}
+
+// ClusterSummary describes a homophone group found by clustering
+type ClusterSummary struct {
+	Positions int     `json:"positions"`
+	Total     int     `json:"total"`
+	Min       int     `json:"min"`
+	Max       int     `json:"max"`
+	Mean      float64 `json:"mean"`
+	Stdev     float64 `json:"stdev"`
+}
+
+// Report summarizes the statistics of a ciphertext
+type Report struct {
+	Total     int             `json:"total"`
+	Distinct  int             `json:"distinct"`
+	Dimension image.Dimension `json:"dimension"`
+	// IndexOfCoincidence is the probability of two random symbols of the ciphertext being
+	// the same position; an ideal homophonic cipher approaches 1/Distinct
+	IndexOfCoincidence float64 `json:"index_of_coincidence"`
+	// Histogram of the position frequencies
+	Histogram []HistogramBin `json:"histogram"`
+	// Clusters ordered by ascending frequency
+	Clusters []ClusterSummary `json:"clusters"`
+}
+
+// Report computes the ciphertext statistics; the histogram is divided into at most bins
+// bins and the positions are clustered into n groups using c
+func (a *Analyse) Report(c Clusterer, n, bins int) (*Report, error) {
+	r := &Report{
+		Total:     a.Total,
+		Distinct:  len(a.Frequency),
+		Dimension: a.ExpectedDimension,
+	}
+	if r.Distinct == 0 {
+		return r, nil
+	}
+
+	minCount, maxCount := a.Total, 0
+	var coincidences float64
+	for _, v := range a.Frequency {
+		if v < minCount {
+			minCount = v
+		}
+		if v > maxCount {
+			maxCount = v
+		}
+		coincidences += float64(v) * float64(v-1)
+	}
+	if a.Total > 1 {
+		r.IndexOfCoincidence = coincidences / (float64(a.Total) * float64(a.Total-1))
+	}
+
+	// Equally wide bins covering all frequencies
+	if bins < 1 {
+		bins = 1
+	}
+	width := (maxCount - minCount + bins) / bins
+	for from := minCount; from <= maxCount; from += width {
+		r.Histogram = append(r.Histogram, HistogramBin{From: from, To: from + width - 1})
+	}
+	for _, v := range a.Frequency {
+		r.Histogram[(v-minCount)/width].Positions++
+	}
+
+	groups, err := a.ExtractGroupsWith(c, n)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		var m moments
+		s := ClusterSummary{Positions: len(g.PixelPositions), Total: g.Total, Min: a.Total}
+		for _, p := range g.PixelPositions {
+			v := a.Frequency[p]
+			m.add(v)
+			if v < s.Min {
+				s.Min = v
+			}
+			if v > s.Max {
+				s.Max = v
+			}
+		}
+		s.Mean, s.Stdev = m.stat()
+		r.Clusters = append(r.Clusters, s)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,54 @@
+package crypt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+)
+
+func TestAnalyse_Report(t *testing.T) {
+	p := func(w int) crypt.PixelPosition { return crypt.PixelPosition{Width: w} }
+
+	// Frequencies 1, 2, 3 and 10
+	var in []crypt.PixelPosition
+	for w, n := range []int{1, 2, 3, 10} {
+		for i := 0; i < n; i++ {
+			in = append(in, p(w))
+		}
+	}
+
+	r, err := Load(in).Report(KMeans{}, 2, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 16, r.Total)
+	assert.Equal(t, 4, r.Distinct)
+	assert.Equal(t, 4, r.Dimension.Width)
+	assert.InDelta(t, float64(0+2+6+90)/(16*15), r.IndexOfCoincidence, 1e-9)
+
+	assert.Equal(t, []HistogramBin{
+		{From: 1, To: 3, Positions: 3},
+		{From: 4, To: 6},
+		{From: 7, To: 9},
+		{From: 10, To: 12, Positions: 1},
+	}, r.Histogram)
+
+	assert.Equal(t, []ClusterSummary{
+		{Positions: 3, Total: 6, Min: 1, Max: 3, Mean: 2, Stdev: r.Clusters[0].Stdev},
+		{Positions: 1, Total: 10, Min: 10, Max: 10, Mean: 10},
+	}, r.Clusters)
+	assert.InDelta(t, 0.8165, r.Clusters[0].Stdev, 1e-4)
+
+	// Uniform usage of all positions
+	r, err = Load(blindText256Enc).Report(StdevWalker{}, 0, 10)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, len(r.Histogram), 10)
+	total := 0
+	for _, b := range r.Histogram {
+		total += b.Positions
+	}
+	assert.Equal(t, r.Distinct, total)
+
+	r, err = Load(nil).Report(StdevWalker{}, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, r.Distinct)
+}
@@ -0,0 +1,264 @@
+package crypt
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+)
+
+// LanguageModel scores plaintexts given as indices into its alphabet, it is implemented by lm.Model
+type LanguageModel interface {
+	// Alphabet returns the plaintext symbols
+	Alphabet() string
+	// Quadgram returns the log10 probability of a quadgram
+	Quadgram(a, b, c, d int) float64
+}
+
+// Solver breaks the cipher as homophonic substitution: every pixel position (or group of
+// positions) is assigned a plaintext symbol such that the decryption is most likely under
+// the language model. The assignment is searched using simulated annealing with restarts.
+type Solver struct {
+	Model LanguageModel
+	// Restarts is the number of independent annealing runs, executed concurrently
+	Restarts int
+	// Iterations per unit and restart
+	Iterations int
+	// Temperature at the beginning of a run relative to the change of the log10 probability
+	// per quadgram, it decreases linearly to 0
+	Temperature float64
+	// Seed makes the search reproducible
+	Seed int64
+}
+
+// NewSolver creates a Solver with default parameters
+func NewSolver(m LanguageModel) *Solver {
+	return &Solver{
+		Model:       m,
+		Restarts:    8,
+		Iterations:  2000,
+		Temperature: 0.25,
+		Seed:        1,
+	}
+}
+
+// Solution is the most likely decryption found by the Solver
+type Solution struct {
+	Plaintext string
+	// Key maps every position of the ciphertext to a plaintext symbol
+	Key map[crypt.PixelPosition]byte
+	// Score is the mean log10 probability per quadgram
+	Score float64
+}
+
+// Solve assigns a plaintext symbol to every distinct position of the ciphertext
+func (s *Solver) Solve(in []crypt.PixelPosition) (*Solution, error) {
+	return s.SolveGroups(in, nil)
+}
+
+// SolveGroups assigns a plaintext symbol to every group, e.g. recovered by LinkAlignedCiphertexts.
+// Positions which are not part of any group are solved individually.
+func (s *Solver) SolveGroups(in []crypt.PixelPosition, groups []PixelGroupFrequency) (*Solution, error) {
+	if len(in) < 4 {
+		return nil, fmt.Errorf("ciphertext is too short, at least 4 symbols are required")
+	}
+
+	// Map positions to units, each unit is assigned one symbol
+	units := make(map[crypt.PixelPosition]int)
+	for i, g := range groups {
+		for _, p := range g.PixelPositions {
+			if _, ok := units[p]; ok {
+				return nil, fmt.Errorf("position %dx%d is part of multiple groups", p.Width, p.Height)
+			}
+			units[p] = i
+		}
+	}
+	n := len(groups)
+	text := make([]int, len(in))
+	for i, p := range in {
+		u, ok := units[p]
+		if !ok {
+			u = n
+			units[p] = u
+			n++
+		}
+		text[i] = u
+	}
+
+	p := newProblem(s.Model, text, n)
+
+	restarts := s.Restarts
+	if restarts < 1 {
+		restarts = 1
+	}
+	results := make([]*annealing, restarts)
+	var wg sync.WaitGroup
+	for r := range results {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			results[r] = p.anneal(rand.New(rand.NewSource(s.Seed+int64(r))), s.Iterations*n, s.Temperature)
+		}(r)
+	}
+	wg.Wait()
+
+	best := results[0]
+	for _, r := range results[1:] {
+		if r.score > best.score {
+			best = r
+		}
+	}
+
+	sol := &Solution{
+		Key:   make(map[crypt.PixelPosition]byte, len(units)),
+		Score: best.score / float64(len(text)-3),
+	}
+	for pos, u := range units {
+		sol.Key[pos] = s.Model.Alphabet()[best.key[u]]
+	}
+	out := make([]byte, len(text))
+	for i, u := range text {
+		out[i] = s.Model.Alphabet()[best.key[u]]
+	}
+	sol.Plaintext = string(out)
+
+	return sol, nil
+}
+
+// problem is a ciphertext of units prepared for scoring changes of a single unit
+type problem struct {
+	model LanguageModel
+	text  []int
+	units int
+	// affected contains the start offsets of all quadgrams containing a unit
+	affected [][]int
+}
+
+func newProblem(m LanguageModel, text []int, units int) *problem {
+	p := &problem{
+		model:    m,
+		text:     text,
+		units:    units,
+		affected: make([][]int, units),
+	}
+
+	// last contains the last offset added per unit, avoiding duplicates of close occurrences
+	last := make([]int, units)
+	for i := range last {
+		last[i] = -1
+	}
+	for i, u := range text {
+		start := i - 3
+		if start <= last[u] {
+			start = last[u] + 1
+		}
+		if start < 0 {
+			start = 0
+		}
+		for j := start; j <= i && j+3 < len(text); j++ {
+			p.affected[u] = append(p.affected[u], j)
+			last[u] = j
+		}
+	}
+
+	return p
+}
+
+// annealing is the state of a single annealing run
+type annealing struct {
+	key   []int
+	score float64
+}
+
+// score returns the sum of the quadgram log probabilities starting at the offsets
+func (p *problem) score(plain []int, offsets []int) float64 {
+	var out float64
+	for _, i := range offsets {
+		out += p.model.Quadgram(plain[i], plain[i+1], plain[i+2], plain[i+3])
+	}
+	return out
+}
+
+// assign updates the decryption of all occurrences of a unit
+func (p *problem) assign(plain []int, key []int, u, c int) {
+	key[u] = c
+	for _, i := range p.affected[u] {
+		for j := i; j < i+4; j++ {
+			if p.text[j] == u {
+				plain[j] = c
+			}
+		}
+	}
+}
+
+// change assigns a symbol to a unit and returns the change of the score
+func (p *problem) change(plain []int, key []int, u, c int) float64 {
+	before := p.score(plain, p.affected[u])
+	p.assign(plain, key, u, c)
+	return p.score(plain, p.affected[u]) - before
+}
+
+func (p *problem) anneal(rnd *rand.Rand, iterations int, temperature float64) *annealing {
+	symbols := len(p.model.Alphabet())
+
+	key := make([]int, p.units)
+	for u := range key {
+		key[u] = rnd.Intn(symbols)
+	}
+	plain := make([]int, len(p.text))
+	for i, u := range p.text {
+		plain[i] = key[u]
+	}
+
+	var score float64
+	for i := 0; i+3 < len(plain); i++ {
+		score += p.model.Quadgram(plain[i], plain[i+1], plain[i+2], plain[i+3])
+	}
+	best := &annealing{key: append([]int{}, key...), score: score}
+
+	for it := 0; it < iterations; it++ {
+		t := temperature * (1 - float64(it)/float64(iterations))
+
+		// Either reassign a unit or swap the symbols of two units, swaps escape local optima
+		// of texts with few units
+		u, v := rnd.Intn(p.units), -1
+		oldU, oldV := key[u], -1
+		var delta float64
+		var affected int
+		if rnd.Intn(2) == 0 && p.units > 1 {
+			v = rnd.Intn(p.units)
+			oldV = key[v]
+			if oldU == oldV {
+				continue
+			}
+			delta = p.change(plain, key, u, oldV) + p.change(plain, key, v, oldU)
+			affected = len(p.affected[u]) + len(p.affected[v])
+		} else {
+			c := rnd.Intn(symbols - 1)
+			if c >= oldU {
+				c++
+			}
+			delta = p.change(plain, key, u, c)
+			affected = len(p.affected[u])
+		}
+
+		// The temperature relates to the change per affected quadgram, independent of the
+		// number of occurrences of a unit
+		if delta >= 0 || (t > 0 && rnd.Float64() < math.Exp(delta/float64(affected)/t)) {
+			score += delta
+			if score > best.score {
+				best.score = score
+				copy(best.key, key)
+			}
+		} else {
+			if v >= 0 {
+				p.assign(plain, key, v, oldV)
+			}
+			p.assign(plain, key, u, oldU)
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,71 @@
+package crypt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+	"github.com/xvzf/htw-crypto-project/pkg/lm"
+)
+
+// accuracy returns the fraction of matching symbols
+func accuracy(a, b string) float64 {
+	match := 0
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			match++
+		}
+	}
+	return float64(match) / float64(len(a))
+}
+
+func TestSolver_Solve(t *testing.T) {
+	m, err := lm.Train(strings.NewReader(blindText), lm.Normalizations["words"])
+	assert.NoError(t, err)
+
+	// Small key with 4 homophones per symbol
+	key, err := image.Generate(image.Dimension{Width: 32, Height: 16}, image.GenerateOptions{
+		Mask:    image.MaskASCII,
+		Balance: true,
+	})
+	assert.NoError(t, err)
+	c, err := crypt.New(key)
+	assert.NoError(t, err)
+
+	plain := string(m.Normalize([]byte(blindText)))[:1500]
+	enc, err := c.Encrypt(plain)
+	assert.NoError(t, err)
+
+	s := NewSolver(m)
+	s.Iterations = 500
+	sol, err := s.Solve(enc)
+	assert.NoError(t, err)
+	assert.Len(t, sol.Plaintext, len(plain))
+	assert.Greater(t, accuracy(plain, sol.Plaintext), 0.9)
+	for i, p := range enc {
+		assert.Equal(t, sol.Plaintext[i], sol.Key[p])
+	}
+
+	// Reproducible
+	again, err := s.Solve(enc)
+	assert.NoError(t, err)
+	assert.Equal(t, sol.Plaintext, again.Plaintext)
+}
+
+func TestSolver_SolveGroups(t *testing.T) {
+	m, err := lm.Train(strings.NewReader(blindText), lm.Normalizations["words"])
+	assert.NoError(t, err)
+
+	// Linked groups reduce the problem to a monoalphabetic substitution
+	plain := string(m.Normalize([]byte(blindText)))[:600]
+	l := LinkAlignedCiphertexts(encryptMany(t, plain, 50))
+
+	sol, err := NewSolver(m).SolveGroups(encryptMany(t, plain, 1)[0], l.Groups)
+	assert.NoError(t, err)
+	assert.Greater(t, accuracy(plain, sol.Plaintext), 0.9)
+
+	_, err = NewSolver(m).Solve([]crypt.PixelPosition{{}, {}})
+	assert.Error(t, err)
+}
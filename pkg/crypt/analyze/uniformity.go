@@ -0,0 +1,150 @@
+package crypt
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+)
+
+// GroupUniformity is the chi-square test of the pixel selection within a homophone group
+type GroupUniformity struct {
+	Symbol uint8 `json:"symbol"`
+	Pixels int   `json:"pixels"`
+	// Draws is the number of ciphertext positions within the group
+	Draws     int     `json:"draws"`
+	ChiSquare float64 `json:"chi_square"`
+	// PValue is the probability of a deviation at least this large for a uniform selection
+	PValue float64 `json:"p_value"`
+}
+
+// Uniformity measures whether the homophones of every symbol are selected uniformly. An
+// unbiased implementation yields p-values uniformly distributed in [0, 1], values close to 0
+// indicate a biased selection. The test is reliable once every pixel of a group is expected
+// to be drawn about five times.
+type Uniformity struct {
+	// Groups sorted by ascending p-value, groups without draws or with a single pixel are omitted
+	Groups []GroupUniformity `json:"groups"`
+	// Combined test over all groups
+	ChiSquare        float64 `json:"chi_square"`
+	DegreesOfFreedom int     `json:"degrees_of_freedom"`
+	PValue           float64 `json:"p_value"`
+}
+
+// Uniformity tests the selection within the pixel groups of the key. The positions have to
+// refer to the key pixels, i.e. ciphertexts using a nonce have to be un-permuted first.
+// Flattened ciphertexts (see Header.Flattened) only draw from a subset of every group and
+// always appear biased.
+func (a *Analyse) Uniformity(groups crypt.PixelGroups) (*Uniformity, error) {
+	symbols := make(map[crypt.PixelPosition]uint8)
+	for s, g := range groups {
+		for _, p := range g {
+			symbols[p] = s
+		}
+	}
+
+	draws := make(map[uint8]int)
+	for p, n := range a.Frequency {
+		s, ok := symbols[p]
+		if !ok {
+			return nil, fmt.Errorf("position %dx%d is not part of the key", p.Width, p.Height)
+		}
+		draws[s] += n
+	}
+
+	u := &Uniformity{}
+	for s, g := range groups {
+		if draws[s] == 0 || len(g) < 2 {
+			continue
+		}
+
+		expected := float64(draws[s]) / float64(len(g))
+		chi := 0.0
+		for _, p := range g {
+			d := float64(a.Frequency[p]) - expected
+			chi += d * d / expected
+		}
+
+		df := len(g) - 1
+		u.Groups = append(u.Groups, GroupUniformity{
+			Symbol:    s,
+			Pixels:    len(g),
+			Draws:     draws[s],
+			ChiSquare: chi,
+			PValue:    chiSquareSurvival(chi, df),
+		})
+		u.ChiSquare += chi
+		u.DegreesOfFreedom += df
+	}
+	if len(u.Groups) == 0 {
+		return nil, fmt.Errorf("no group with at least two pixels has been drawn")
+	}
+	u.PValue = chiSquareSurvival(u.ChiSquare, u.DegreesOfFreedom)
+
+	sort.SliceStable(u.Groups, func(i, j int) bool {
+		if u.Groups[i].PValue != u.Groups[j].PValue {
+			return u.Groups[i].PValue < u.Groups[j].PValue
+		}
+		return u.Groups[i].Symbol < u.Groups[j].Symbol
+	})
+
+	return u, nil
+}
+
+// chiSquareSurvival returns P(X >= x) for a chi-square distribution with df degrees of freedom
+func chiSquareSurvival(x float64, df int) float64 {
+	if x <= 0 {
+		return 1
+	}
+	return gammaQ(float64(df)/2, x/2)
+}
+
+// gammaQ is the regularized upper incomplete gamma function, evaluated by its series below
+// a+1 and by its continued fraction above (Numerical Recipes 6.2)
+func gammaQ(a, x float64) float64 {
+	const (
+		iterations = 1000
+		eps        = 1e-14
+		tiny       = 1e-300
+	)
+	lg, _ := math.Lgamma(a)
+	prefix := math.Exp(a*math.Log(x) - x - lg)
+
+	if x < a+1 {
+		sum, term := 1/a, 1/a
+		for n := 1; n < iterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*eps {
+				break
+			}
+		}
+		return math.Max(0, 1-sum*prefix)
+	}
+
+	// Modified Lentz's method
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for n := 1; n < iterations; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < eps {
+			break
+		}
+	}
+	return prefix * h
+}
@@ -0,0 +1,47 @@
+package crypt
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+)
+
+func TestChiSquareSurvival(t *testing.T) {
+	// Reference values of the chi-square distribution
+	assert.InDelta(t, 0.05, chiSquareSurvival(3.841459, 1), 1e-6)
+	assert.InDelta(t, 0.05, chiSquareSurvival(18.307038, 10), 1e-6)
+	assert.InDelta(t, 0.01, chiSquareSurvival(135.806723, 100), 1e-6)
+	assert.InDelta(t, 0.5, chiSquareSurvival(9999.333, 10000), 1e-3)
+	assert.Equal(t, 1.0, chiSquareSurvival(0, 5))
+}
+
+func TestAnalyse_Uniformity(t *testing.T) {
+	plain := strings.Repeat("e", 20000)
+
+	enc, err := cipher.Encrypt(plain)
+	assert.NoError(t, err)
+	u, err := Load(enc).Uniformity(cipher.PixelGroups)
+	assert.NoError(t, err)
+	assert.Len(t, u.Groups, 1)
+	assert.Equal(t, uint8('e'), u.Groups[0].Symbol)
+	assert.Equal(t, len(plain), u.Groups[0].Draws)
+	assert.Greater(t, u.PValue, 1e-6)
+
+	// The first pixel of the group is selected twice as often
+	group := cipher.PixelGroups['e']
+	r := rand.New(rand.NewSource(1))
+	biased := make([]crypt.PixelPosition, 0, len(plain))
+	for range plain {
+		biased = append(biased, group[r.Intn(len(group)+1)%len(group)])
+	}
+	u, err = Load(biased).Uniformity(cipher.PixelGroups)
+	assert.NoError(t, err)
+	assert.Less(t, u.PValue, 1e-6)
+
+	// Positions have to be part of the key
+	_, err = Load([]crypt.PixelPosition{{Width: 1 << 20}}).Uniformity(cipher.PixelGroups)
+	assert.Error(t, err)
+}
@@ -0,0 +1,76 @@
+package crypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+// Authenticated ciphertexts append a tag to every frame, including the terminating one:
+//
+//	tag = HMAC-SHA256(key, SHA256(header) | frame index | final | frame)[:TagSize]
+//
+// Binding the tag to the raw header and the frame index detects modified headers,
+// reordered, removed and spliced frames; the final flag detects truncation.
+
+// TagSize is the length of a frame authentication tag in bytes
+const TagSize = 16
+
+// authHMACSHA256 identifies the authentication scheme in the header
+const authHMACSHA256 uint8 = 1
+
+// ErrAuthentication is returned when a ciphertext fails authentication
+var ErrAuthentication = errors.New("ciphertext authentication failed")
+
+// WithAuthentication enables authenticated ciphertexts. Encryption adds a tag to every
+// frame, decryption rejects ciphertexts without authentication.
+func WithAuthentication() Option {
+	return func(c *Container) {
+		c.Authenticate = true
+	}
+}
+
+// authKey derives the authentication key from the key image
+func authKey(i *image.Image) []byte {
+	return image.Hash(i, "htw-crypto-project authentication key")
+}
+
+// authenticator computes the tags of consecutive frames
+type authenticator struct {
+	mac    hash.Hash
+	header [sha256.Size]byte
+	index  uint64
+}
+
+func newAuthenticator(key, header []byte) *authenticator {
+	return &authenticator{
+		mac:    hmac.New(sha256.New, key),
+		header: sha256.Sum256(header),
+	}
+}
+
+// tag returns the tag of the next frame
+func (a *authenticator) tag(frame []byte, final bool) []byte {
+	a.mac.Reset()
+	a.mac.Write(a.header[:])
+
+	meta := make([]byte, 9)
+	binary.BigEndian.PutUint64(meta[:8], a.index)
+	if final {
+		meta[8] = 1
+	}
+	a.mac.Write(meta)
+	a.mac.Write(frame)
+	a.index++
+
+	return a.mac.Sum(nil)[:TagSize]
+}
+
+// verify checks the tag of the next frame
+func (a *authenticator) verify(frame []byte, final bool, tag []byte) bool {
+	return hmac.Equal(a.tag(frame, final), tag)
+}
@@ -0,0 +1,134 @@
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encryptStream(t *testing.T, c *Container, plain string) []byte {
+	var buf bytes.Buffer
+	ew, err := c.NewEncryptWriter(&buf)
+	assert.NoError(t, err)
+	_, err = ew.Write([]byte(plain))
+	assert.NoError(t, err)
+	assert.NoError(t, ew.Close())
+	return buf.Bytes()
+}
+
+func decryptStream(c *Container, ct []byte) (string, error) {
+	dr, err := c.NewDecryptReader(bytes.NewReader(ct))
+	if err != nil {
+		return "", err
+	}
+	dec, err := ioutil.ReadAll(dr)
+	return string(dec), err
+}
+
+// splitAuthenticated splits an authenticated ciphertext into the header and frames including their tags
+func splitAuthenticated(t *testing.T, ct []byte) ([]byte, [][]byte) {
+	r := bufio.NewReader(bytes.NewReader(ct))
+	h, header, err := readHeader(r)
+	assert.NoError(t, err)
+	assert.True(t, h.Authenticated)
+
+	var frames [][]byte
+	for {
+		raw, err := readFrame(r, h.Dimension)
+		assert.NoError(t, err)
+		tag := make([]byte, TagSize)
+		_, err = io.ReadFull(r, tag)
+		assert.NoError(t, err)
+		frames = append(frames, append(raw, tag...))
+		if raw[0] == 0 {
+			return header, frames
+		}
+	}
+}
+
+func join(header []byte, frames ...[]byte) []byte {
+	return bytes.Join(append([][]byte{header}, frames...), nil)
+}
+
+func TestAuthentication(t *testing.T) {
+	c, err := New(cipher.Image, WithAuthentication())
+	assert.NoError(t, err)
+
+	plain := strings.Repeat("attack at dawn ", 3*ChunkSize/15+1)
+	ct := encryptStream(t, c, plain)
+
+	// Untouched ciphertext
+	dec, err := decryptStream(c, ct)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, dec)
+
+	// Parsing requires the key unless verification is skipped explicitly
+	_, _, err = Read(bytes.NewReader(ct))
+	assert.True(t, errors.Is(err, ErrAuthentication))
+	_, enc, err := c.Read(bytes.NewReader(ct))
+	assert.NoError(t, err)
+	assert.Len(t, enc, len(plain))
+	cr, err := NewReader(bytes.NewReader(ct))
+	assert.NoError(t, err)
+	cr.SkipAuthentication()
+	_, unverified, err := readAll(cr)
+	assert.NoError(t, err)
+	assert.Equal(t, enc, unverified)
+
+	// Positions carry no tags
+	_, err = c.Decrypt(enc)
+	assert.True(t, errors.Is(err, ErrAuthentication))
+
+	header, frames := splitAuthenticated(t, ct)
+	assert.Len(t, frames, 5)
+	assert.Equal(t, ct, join(header, frames...))
+
+	for name, mod := range map[string][]byte{
+		"modified position": func() []byte {
+			m := append([]byte{}, ct...)
+			m[len(m)/2] ^= 0x01
+			return m
+		}(),
+		"reordered frames": join(header, frames[1], frames[0], frames[2], frames[3], frames[4]),
+		"removed frame":    join(header, frames[0], frames[2], frames[3], frames[4]),
+		"truncated":        join(header, frames[0], frames[1]),
+		"spliced":          join(header, frames[0], frames[4]),
+		"modified header": func() []byte {
+			// Drop the fingerprint record, the ciphertext is still accepted by Check
+			h := &Header{Dimension: c.Image.Dimension, Mask: c.Mask, Authenticated: true}
+			return join(encodeHeader(h), frames...)
+		}(),
+	} {
+		_, err := decryptStream(c, mod)
+		assert.Error(t, err, name)
+		_, _, err = c.Read(bytes.NewReader(mod))
+		assert.Error(t, err, name)
+
+		// Nothing is released before the whole ciphertext has been verified
+		_, err = c.NewVerifiedDecryptReader(bytes.NewReader(mod))
+		assert.Error(t, err, name)
+	}
+	dr, err := c.NewVerifiedDecryptReader(bytes.NewReader(ct))
+	assert.NoError(t, err)
+	verified, err := ioutil.ReadAll(dr)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, string(verified))
+
+	// Frame tags are verified before positions are returned
+	_, err = decryptStream(c, join(header, frames[1], frames[0]))
+	assert.True(t, errors.Is(err, ErrAuthentication))
+
+	// Authenticated ciphertexts are verified even if not required
+	_, err = decryptStream(cipher, join(header, frames[0], frames[4]))
+	assert.True(t, errors.Is(err, ErrAuthentication))
+
+	// Decryption without authentication is rejected if authentication is required
+	_, err = decryptStream(c, encryptStream(t, cipher, "hello"))
+	assert.True(t, errors.Is(err, ErrAuthentication))
+}
@@ -0,0 +1,80 @@
+package crypt
+
+import (
+	"crypto/aes"
+	gocipher "crypto/cipher"
+
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+// In chaining mode the symbol value searched for a plaintext byte depends on the previously
+// emitted position, analogous to CBC:
+//
+//	value = (byte + offset[previous position]) & mask
+//
+// The offsets are a keystream of AES-256-CTR keyed by a secret derived from the key image,
+// one byte per pixel plus one for the first symbol of a message. Identical plaintext bytes
+// are therefore represented by different pixel groups depending on their predecessor.
+
+// chainingCTR identifies the chaining scheme in the header
+const chainingCTR uint8 = 1
+
+// WithChaining enables chained homophone selection
+func WithChaining() Option {
+	return func(c *Container) {
+		c.Chaining = true
+	}
+}
+
+// chainOffsets derives the offset of every pixel from the key image
+func chainOffsets(i *image.Image) []uint8 {
+	block, err := aes.NewCipher(image.Hash(i, "htw-crypto-project chaining key"))
+	if err != nil {
+		// A SHA-256 hash is always a valid AES-256 key
+		panic(err)
+	}
+
+	offsets := make([]uint8, len(i.Data)+1)
+	gocipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(offsets, offsets)
+	return offsets
+}
+
+// chainer tracks the offset applied to the next symbol of a message
+type chainer struct {
+	offsets []uint8
+	width   int
+	mask    uint8
+	next    uint8
+}
+
+// newChainer returns the chaining state for a new message, nil if chained is false
+func (c *Container) newChainer(chained bool) *chainer {
+	if !chained {
+		return nil
+	}
+
+	c.chainOnce.Do(func() {
+		c.chainOffsets = chainOffsets(c.Image)
+	})
+	return &chainer{
+		offsets: c.chainOffsets,
+		width:   c.Image.Dimension.Width,
+		mask:    c.Mask,
+		next:    c.chainOffsets[len(c.chainOffsets)-1],
+	}
+}
+
+// encode returns the symbol value representing a plaintext byte
+func (ch *chainer) encode(b uint8) uint8 {
+	return (b + ch.next) & ch.mask
+}
+
+// decode returns the plaintext byte represented by a symbol value
+func (ch *chainer) decode(v uint8) uint8 {
+	return (v - ch.next) & ch.mask
+}
+
+// advance updates the offset using the emitted position
+func (ch *chainer) advance(p PixelPosition) {
+	ch.next = ch.offsets[p.Width+ch.width*p.Height]
+}
@@ -0,0 +1,79 @@
+package crypt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainer_EncryptChained(t *testing.T) {
+	c, err := New(cipher.Image, WithChaining())
+	assert.NoError(t, err)
+
+	plain := strings.Repeat("e", 1000)
+	enc, err := c.Encrypt(plain)
+	assert.NoError(t, err)
+	dec, err := c.Decrypt(enc)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, dec)
+
+	// The same letter is represented by many different pixel groups
+	unchained, err := cipher.Decrypt(enc)
+	assert.NoError(t, err)
+	symbols := make(map[byte]bool)
+	for _, b := range []byte(unchained) {
+		symbols[b] = true
+	}
+	assert.Greater(t, len(symbols), 64)
+
+	// Decrypting a suffix fails from the second symbol on, the chain restarts
+	dec, err = c.Decrypt(enc[1:])
+	assert.NoError(t, err)
+	assert.NotEqual(t, plain[1:], dec)
+
+	_, err = c.Decrypt(Encrypted{{Width: 1 << 20}})
+	assert.Error(t, err)
+
+	_, err = New(cipher.Image, WithChaining(), WithFlattening(English()))
+	assert.Error(t, err)
+}
+
+func TestChaining_Stream(t *testing.T) {
+	c, err := New(cipher.Image, WithChaining(), WithNonce())
+	assert.NoError(t, err)
+
+	// The chain continues across frames
+	plain := string(bytes.Repeat([]byte("abc"), ChunkSize))
+	ct := encryptStream(t, c, plain)
+
+	h, _, err := Read(bytes.NewReader(ct))
+	assert.NoError(t, err)
+	assert.True(t, h.Chained)
+
+	// The mode is taken from the header
+	for _, d := range []*Container{c, cipher} {
+		dec, err := decryptStream(d, ct)
+		assert.NoError(t, err)
+		assert.Equal(t, plain, dec)
+	}
+
+	// JSON ciphertexts require the option for decryption
+	c, err = New(cipher.Image, WithChaining())
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	cw, err := NewWriter(&buf, c.Header(), FormatJSON)
+	assert.NoError(t, err)
+	ew := c.EncryptFrames(cw)
+	_, err = ew.Write([]byte(plain))
+	assert.NoError(t, err)
+	assert.NoError(t, ew.Close())
+
+	dec, err := decryptStream(c, buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, plain, dec)
+	dec, err = decryptStream(cipher, buf.Bytes())
+	assert.NoError(t, err)
+	assert.NotEqual(t, plain, dec)
+}
@@ -4,21 +4,27 @@ package crypt
 
 import (
 	"crypto/rand"
-	"encoding/binary"
 	"errors"
+	"fmt"
 
 	"github.com/xvzf/htw-crypto-project/pkg/image"
+	"github.com/xvzf/htw-crypto-project/pkg/random"
 )
 
 // ExtractGroups extract Pixel groups of an image
 func ExtractGroups(i *image.Image) PixelGroups {
+	return ExtractGroupsMask(i, image.MaskASCII)
+}
+
+// ExtractGroupsMask extract Pixel groups of an image, pixel values are ANDed with mask
+func ExtractGroupsMask(i *image.Image, mask uint8) PixelGroups {
 
 	p := make(PixelGroups)
 
 	// Iterate through all pixels & update the grouping
 	for h := 0; h < i.Dimension.Height; h++ {
 		for w := 0; w < i.Dimension.Width; w++ {
-			v := i.Data[w+i.Dimension.Width*h] & 0b01111111
+			v := i.Data[w+i.Dimension.Width*h] & mask
 			if _, ok := p[v]; !ok {
 				p[v] = []PixelPosition{{w, h}}
 			} else {
@@ -31,44 +37,139 @@ func ExtractGroups(i *image.Image) PixelGroups {
 	return p
 }
 
+// WithFullByte enables the byte-clean mode mapping all 256 byte values on the image
+func WithFullByte() Option {
+	return func(c *Container) {
+		c.Mask = image.MaskByte
+	}
+}
+
+// WithLenient skips plaintext bytes without a pixel group instead of returning an
+// UnrepresentableByteError. The skipped bytes are missing in the ciphertext.
+func WithLenient() Option {
+	return func(c *Container) {
+		c.Lenient = true
+	}
+}
+
 // New creates a new container allowing for endcryption&decryption
-func New(i *image.Image) (*Container, error) {
-	if !image.CheckAccept(i) {
+func New(i *image.Image, opts ...Option) (*Container, error) {
+	c := &Container{
+		Image: i,
+		Mask:  image.MaskASCII,
+		Rand:  rand.Reader,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// Chaining selects the groups regardless of the plaintext distribution, trimmed groups
+	// of rare symbols would be hit repeatedly
+	if c.Chaining && c.Flattening != nil {
+		return nil, errors.New("chaining can not be combined with flattening")
+	}
+
+	if !image.CheckAcceptMask(i, c.Mask) {
 		return nil, errors.New("Image not suiteable")
 	}
-	c := &Container{
-		Image:       i,
-		PixelGroups: ExtractGroups(i),
+	c.PixelGroups = ExtractGroupsMask(i, c.Mask)
+	c.homophones = c.PixelGroups
+	if c.Flattening != nil {
+		var err error
+		if c.homophones, err = flatten(c.PixelGroups, c.Flattening, c.Rand); err != nil {
+			return nil, err
+		}
+	}
+	if c.Ledger != nil {
+		if err := c.Ledger.check(i); err != nil {
+			return nil, err
+		}
 	}
+	c.Fingerprint = image.Fingerprint(i)
+	c.authKey = authKey(i)
+	c.permKey = permutationKey(i)
 
 	return c, nil
 }
 
-// Encrypt allows encryption of an arbitrary ASCII string
+// Encrypt allows encryption of an arbitrary ASCII string (or any byte string in byte-clean mode)
 func (c *Container) Encrypt(s string) (Encrypted, error) {
-	enc := make(Encrypted, len(s))
+	return c.encrypt(s, c.newMessage(c.Chaining))
+}
 
-	rnd := make([]byte, 4)
+// message is the pixel selection state carried across the frames of a message
+type message struct {
+	rnd   *random.Sampler
+	chain *chainer
+	pool  *pool
+}
+
+func (c *Container) newMessage(chained bool) *message {
+	rnd := random.NewSampler(c.Rand)
+	return &message{rnd: rnd, chain: c.newChainer(chained), pool: c.newPool(rnd)}
+}
+
+// encrypt encrypts s continuing the state of message m
+func (c *Container) encrypt(s string, m *message) (Encrypted, error) {
+	enc := make(Encrypted, 0, len(s))
 
 	// Iterate over the input string, determine (random) pixel position
 	for i, b := range []uint8(s) {
-		if pixelGroup, ok := c.PixelGroups[b]; ok {
-			// Get the number of available options for the pixel value
-			availOptions := len(pixelGroup)
-			// Choose a random position out of the pixel group
-			rand.Read(rnd)
-			d := binary.BigEndian.Uint32(rnd)
-
-			ppos := pixelGroup[int(d)%availOptions]
-			enc[i] = ppos
+		pixelGroup, ok := c.homophones[b]
+		if !ok {
+			if c.Lenient {
+				continue
+			}
+			return nil, &UnrepresentableByteError{Offset: i, Value: b}
+		}
+		v := b
+		if m.chain != nil {
+			v = m.chain.encode(b)
+			pixelGroup = c.homophones[v]
+		}
+
+		var pos PixelPosition
+		if m.pool != nil {
+			var err error
+			if pos, err = m.pool.draw(v); err != nil {
+				var exErr *ExhaustedError
+				if errors.As(err, &exErr) {
+					exErr.Offset, exErr.Value = i, b
+				}
+				return nil, err
+			}
+		} else {
+			// Choose an unbiased random position out of the pixel group
+			j, err := m.rnd.Intn(len(pixelGroup))
+			if err != nil {
+				return nil, err
+			}
+			pos = pixelGroup[j]
 		}
+
+		if m.chain != nil {
+			m.chain.advance(pos)
+		}
+		enc = append(enc, pos)
 	}
 
+	if c.Ledger != nil {
+		c.Ledger.Use(enc)
+	}
 	return enc, nil
 }
 
-// Decrypt allows decryption of an arbitrary encrypted ASCII string
+// Decrypt allows decryption of an arbitrary encrypted ASCII string. Positions carry no
+// authentication tags, containers requiring authentication have to use NewVerifiedDecryptReader.
 func (c *Container) Decrypt(enc Encrypted) (string, error) {
+	if c.Authenticate {
+		return "", fmt.Errorf("%w: positions can not be verified", ErrAuthentication)
+	}
+	return c.decrypt(enc, c.newChainer(c.Chaining))
+}
+
+// decrypt decrypts enc continuing the chaining state ch, which is nil without chaining
+func (c *Container) decrypt(enc Encrypted, ch *chainer) (string, error) {
 	dec := make([]byte, len(enc))
 
 	for i, ec := range enc {
@@ -77,7 +178,11 @@ func (c *Container) Decrypt(enc Encrypted) (string, error) {
 			// Calculate pixel position in the slice
 			arrayPos := ec.Width + c.Image.Dimension.Width*ec.Height
 			// Retrieve Byte
-			dec[i] = byte(c.Image.Data[arrayPos] & 0b01111111)
+			dec[i] = byte(c.Image.Data[arrayPos] & c.Mask)
+			if ch != nil {
+				dec[i] = ch.decode(dec[i])
+				ch.advance(ec)
+			}
 		} else {
 			// Invalid pixel position
 			return "", errors.New("Invalid pixel position")
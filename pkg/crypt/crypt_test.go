@@ -1,10 +1,12 @@
 package crypt
 
 import (
+	"errors"
 	"log"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/drbg"
 	"github.com/xvzf/htw-crypto-project/pkg/image"
 )
 
@@ -23,8 +25,8 @@ func init() {
 		i = image.Mock()
 	}
 
-	// Build new crypt engine
-	cipher, err = New(i)
+	// Build new crypt engine, seeded for reproducible test runs
+	cipher, err = New(i, WithRand(drbg.New([]byte("test"))))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -90,6 +92,53 @@ func TestContainer_Encrypt_Decrypt(t *testing.T) {
 	}
 }
 
+func TestContainer_Encrypt_Decrypt_FullByte(t *testing.T) {
+	// Generate valid test image
+	i := image.Mock()
+	for !image.CheckAcceptMask(i, image.MaskByte) {
+		i = image.Mock()
+	}
+
+	// Build new crypt engine
+	cipher, err := New(i, WithFullByte())
+	assert.NoError(t, err)
+
+	// All byte values, including the ones >= 128
+	buf := make([]byte, 4096)
+	for i := range buf {
+		buf[i] = byte(i % 256)
+	}
+
+	for _, s := range []string{
+		"héllo wörld ✓",
+		string(buf),
+	} {
+		enc, err := cipher.Encrypt(s)
+		assert.NoError(t, err)
+		dec, err := cipher.Decrypt(enc)
+		assert.NoError(t, err)
+		assert.Equal(t, s, dec)
+	}
+}
+
+func TestContainer_Encrypt_Unrepresentable(t *testing.T) {
+	// "ä" is encoded as 0xc3 0xa4 -> not part of the 7-bit alphabet
+	_, err := cipher.Encrypt("abä")
+	var ubErr *UnrepresentableByteError
+	assert.True(t, errors.As(err, &ubErr))
+	assert.Equal(t, 2, ubErr.Offset)
+	assert.Equal(t, byte(0xc3), ubErr.Value)
+
+	// Lenient mode skips the bytes
+	lenient, err := New(cipher.Image, WithLenient())
+	assert.NoError(t, err)
+	enc, err := lenient.Encrypt("abä")
+	assert.NoError(t, err)
+	dec, err := lenient.Decrypt(enc)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", dec)
+}
+
 func BenchmarkContainer_Encrypt1Mbyte(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		cipher.Encrypt(testData1MByte)
@@ -0,0 +1,129 @@
+package crypt
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/xvzf/htw-crypto-project/pkg/random"
+)
+
+// Distribution contains the expected relative frequency of every plaintext byte
+type Distribution [256]float64
+
+// englishLetters contains the relative letter frequencies of English texts, based on
+// https://www3.nd.edu/~busiforc/handouts/cryptography/letterfrequencies.html
+var englishLetters = map[byte]float64{
+	'a': 8.167, 'b': 1.492, 'c': 2.782, 'd': 4.253, 'e': 12.702, 'f': 2.228, 'g': 2.015,
+	'h': 6.094, 'i': 6.966, 'j': 0.153, 'k': 0.772, 'l': 4.025, 'm': 2.406, 'n': 6.749,
+	'o': 7.507, 'p': 1.929, 'q': 0.095, 'r': 5.987, 's': 6.327, 't': 9.056, 'u': 2.758,
+	'v': 0.978, 'w': 2.360, 'x': 0.150, 'y': 1.974, 'z': 0.074,
+}
+
+// English returns the byte distribution of English prose: roughly one space per five
+// letters, mostly lowercase letters and a small share of punctuation and newlines.
+func English() Distribution {
+	var d Distribution
+
+	for l, f := range englishLetters {
+		d[l] = 0.95 * f
+		d[l-'a'+'A'] = 0.05 * f
+	}
+	d[' '] = 18
+	d['\n'] = 1
+	for _, p := range []byte(".,;:!?'\"-()") {
+		d[p] = 0.2
+	}
+
+	d.normalize()
+	return d
+}
+
+// DistributionFromCorpus computes the byte distribution of a sample text
+func DistributionFromCorpus(r io.Reader) (Distribution, error) {
+	var d Distribution
+
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return d, err
+		}
+		d[b]++
+	}
+
+	if !d.normalize() {
+		return d, errors.New("empty corpus")
+	}
+	return d, nil
+}
+
+// normalize scales the distribution to a sum of 1, false is returned for an empty distribution
+func (d *Distribution) normalize() bool {
+	var sum float64
+	for _, v := range d {
+		sum += v
+	}
+	if sum == 0 {
+		return false
+	}
+	for i := range d {
+		d[i] /= sum
+	}
+	return true
+}
+
+// WithFlattening limits the number of pixels used per symbol proportional to the expected
+// plaintext distribution, so every used pixel position is emitted with about the same
+// frequency. Symbols not covered by the distribution are mapped to a single pixel.
+func WithFlattening(d Distribution) Option {
+	return func(c *Container) {
+		c.Flattening = &d
+	}
+}
+
+// flatten selects a random subset of every pixel group, sized proportional to the distribution
+func flatten(groups PixelGroups, d *Distribution, r io.Reader) (PixelGroups, error) {
+	// Largest scale at which every symbol still has enough pixels
+	scale := math.Inf(1)
+	for s, g := range groups {
+		if d[s] > 0 {
+			scale = math.Min(scale, float64(len(g))/d[s])
+		}
+	}
+
+	out := make(PixelGroups, len(groups))
+	rnd := random.NewSampler(r)
+	// Symbols are processed in order, a seeded source yields the same subsets
+	for v := 0; v < 256; v++ {
+		s := uint8(v)
+		g, ok := groups[s]
+		if !ok {
+			continue
+		}
+		n := int(scale * d[s])
+		if n < 1 {
+			n = 1
+		}
+		if n > len(g) {
+			n = len(g)
+		}
+
+		// Partial Fisher-Yates shuffle on a copy
+		sub := append([]PixelPosition{}, g...)
+		for i := 0; i < n; i++ {
+			j, err := rnd.Intn(len(sub) - i)
+			if err != nil {
+				return nil, err
+			}
+			sub[i], sub[i+j] = sub[i+j], sub[i]
+		}
+		out[s] = sub[:n]
+	}
+
+	return out, nil
+}
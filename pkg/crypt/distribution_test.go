@@ -0,0 +1,82 @@
+package crypt
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const englishSample = `It was the best of times, it was the worst of times, it was the age of wisdom,
+it was the age of foolishness, it was the epoch of belief, it was the epoch of incredulity,
+it was the season of Light, it was the season of Darkness, it was the spring of hope,
+it was the winter of despair, we had everything before us, we had nothing before us.
+`
+
+// variation returns the coefficient of variation of the pixel position frequencies
+func variation(enc []PixelPosition) float64 {
+	freq := make(map[PixelPosition]float64)
+	for _, p := range enc {
+		freq[p]++
+	}
+
+	var sum, sumSquared float64
+	for _, v := range freq {
+		sum += v
+		sumSquared += v * v
+	}
+	mean := sum / float64(len(freq))
+	return math.Sqrt(sumSquared/float64(len(freq))-mean*mean) / mean
+}
+
+func TestEnglish(t *testing.T) {
+	d := English()
+
+	var sum float64
+	for _, v := range d {
+		sum += v
+	}
+	assert.InDelta(t, 1.0, sum, 1e-9)
+	assert.Greater(t, d[' '], d['e'])
+	assert.Greater(t, d['e'], d['E'])
+	assert.Greater(t, d['e'], d['z'])
+	assert.Zero(t, d[0])
+}
+
+func TestDistributionFromCorpus(t *testing.T) {
+	d, err := DistributionFromCorpus(strings.NewReader("aab"))
+	assert.NoError(t, err)
+	assert.InDelta(t, 2.0/3.0, d['a'], 1e-9)
+	assert.InDelta(t, 1.0/3.0, d['b'], 1e-9)
+
+	_, err = DistributionFromCorpus(strings.NewReader(""))
+	assert.Error(t, err)
+}
+
+func TestContainer_Encrypt_Flattening(t *testing.T) {
+	d, err := DistributionFromCorpus(strings.NewReader(englishSample))
+	assert.NoError(t, err)
+
+	flat, err := New(cipher.Image, WithFlattening(d))
+	assert.NoError(t, err)
+
+	plain := strings.Repeat(englishSample, 200)
+
+	enc, err := cipher.Encrypt(plain)
+	assert.NoError(t, err)
+	encFlat, err := flat.Encrypt(plain)
+	assert.NoError(t, err)
+
+	// Group sizes are proportional to the symbol frequency
+	assert.Greater(t, len(flat.homophones[' ']), len(flat.homophones['b']))
+	assert.Len(t, flat.homophones['Z'], 1)
+
+	// Uniform pixel usage compared to the default mode
+	assert.Greater(t, variation(enc), 0.8)
+	assert.Less(t, variation(encFlat), 0.3)
+
+	dec, err := flat.Decrypt(encFlat)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, dec)
+}
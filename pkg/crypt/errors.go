@@ -0,0 +1,28 @@
+package crypt
+
+import "fmt"
+
+// UnrepresentableByteError is returned when a plaintext byte has no pixel group on the key image
+type UnrepresentableByteError struct {
+	// Offset of the byte within the plaintext
+	Offset int
+	// Value of the byte
+	Value byte
+}
+
+func (e *UnrepresentableByteError) Error() string {
+	return fmt.Sprintf("byte 0x%02x at offset %d can not be represented by the key image", e.Value, e.Offset)
+}
+
+// ExhaustedError is returned when all pixels of the group representing a plaintext byte
+// have been used, see WithExhaustion and WithLedger
+type ExhaustedError struct {
+	// Offset of the byte within the plaintext
+	Offset int
+	// Value of the byte
+	Value byte
+}
+
+func (e *ExhaustedError) Error() string {
+	return fmt.Sprintf("byte 0x%02x at offset %d can not be represented, all pixels of its group have been used", e.Value, e.Offset)
+}
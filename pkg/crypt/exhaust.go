@@ -0,0 +1,97 @@
+package crypt
+
+import (
+	"fmt"
+
+	"github.com/xvzf/htw-crypto-project/pkg/random"
+)
+
+// Exhaustion defines how pixels are selected from a pixel group within a message
+type Exhaustion int
+
+const (
+	// ExhaustNone selects pixels uniformly at random, a pixel may be used repeatedly
+	ExhaustNone Exhaustion = iota
+	// ExhaustReshuffle never reuses a pixel until its group is exhausted, then all pixels
+	// of the group become available again
+	ExhaustReshuffle
+	// ExhaustError never reuses a pixel and returns an ExhaustedError once a group is exhausted
+	ExhaustError
+)
+
+// ParseExhaustion parses the name of an exhaustion policy
+func ParseExhaustion(s string) (Exhaustion, error) {
+	switch s {
+	case "none":
+		return ExhaustNone, nil
+	case "reshuffle":
+		return ExhaustReshuffle, nil
+	case "error":
+		return ExhaustError, nil
+	}
+	return ExhaustNone, fmt.Errorf("unknown exhaustion policy %q", s)
+}
+
+func (e Exhaustion) String() string {
+	switch e {
+	case ExhaustReshuffle:
+		return "reshuffle"
+	case ExhaustError:
+		return "error"
+	}
+	return "none"
+}
+
+// WithExhaustion samples the pixel groups without replacement within a message
+func WithExhaustion(e Exhaustion) Option {
+	return func(c *Container) {
+		c.Exhaustion = e
+	}
+}
+
+// pool keeps the unused pixels of every group within a message
+type pool struct {
+	c    *Container
+	rnd  *random.Sampler
+	free map[uint8][]PixelPosition
+}
+
+// newPool returns the pixel pool for a new message, nil if pixels may be reused
+func (c *Container) newPool(rnd *random.Sampler) *pool {
+	if c.Exhaustion == ExhaustNone && c.Ledger == nil {
+		return nil
+	}
+	return &pool{c: c, rnd: rnd, free: make(map[uint8][]PixelPosition)}
+}
+
+// fill returns all pixels of group v which have not been consumed by the ledger
+func (p *pool) fill(v uint8) []PixelPosition {
+	free := make([]PixelPosition, 0, len(p.c.homophones[v]))
+	for _, pos := range p.c.homophones[v] {
+		if p.c.Ledger == nil || !p.c.Ledger.Used(pos) {
+			free = append(free, pos)
+		}
+	}
+	return free
+}
+
+// draw removes a random unused pixel of group v from the pool
+func (p *pool) draw(v uint8) (PixelPosition, error) {
+	free, ok := p.free[v]
+	if !ok || (len(free) == 0 && p.c.Exhaustion == ExhaustReshuffle && p.c.Ledger == nil) {
+		free = p.fill(v)
+	}
+	if len(free) == 0 {
+		return PixelPosition{}, &ExhaustedError{Value: v}
+	}
+
+	i, err := p.rnd.Intn(len(free))
+	if err != nil {
+		return PixelPosition{}, err
+	}
+	pos := free[i]
+	free[i] = free[len(free)-1]
+	p.free[v] = free[:len(free)-1]
+
+	return pos, nil
+}
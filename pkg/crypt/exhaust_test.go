@@ -0,0 +1,84 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+func TestParseExhaustion(t *testing.T) {
+	for _, e := range []Exhaustion{ExhaustNone, ExhaustReshuffle, ExhaustError} {
+		p, err := ParseExhaustion(e.String())
+		assert.NoError(t, err)
+		assert.Equal(t, e, p)
+	}
+
+	_, err := ParseExhaustion("never")
+	assert.Error(t, err)
+}
+
+func TestContainer_EncryptExhaustion(t *testing.T) {
+	group := len(cipher.PixelGroups['e'])
+
+	c, err := New(cipher.Image, WithExhaustion(ExhaustError))
+	assert.NoError(t, err)
+
+	// Every pixel of the group is used exactly once
+	plain := strings.Repeat("e", group)
+	enc, err := c.Encrypt(plain)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, cipher.PixelGroups['e'], enc)
+	dec, err := c.Decrypt(enc)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, dec)
+
+	_, err = c.Encrypt("ab" + plain + "e")
+	var exErr *ExhaustedError
+	assert.True(t, errors.As(err, &exErr))
+	assert.Equal(t, group+2, exErr.Offset)
+	assert.Equal(t, byte('e'), exErr.Value)
+
+	// Exhausted groups are reused after all of their pixels have been used
+	c, err = New(cipher.Image, WithExhaustion(ExhaustReshuffle))
+	assert.NoError(t, err)
+	enc, err = c.Encrypt(plain + plain)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, cipher.PixelGroups['e'], enc[:group])
+	assert.ElementsMatch(t, cipher.PixelGroups['e'], enc[group:])
+}
+
+func TestExhaustion_Stream(t *testing.T) {
+	key, err := image.Generate(image.Dimension{Width: 512, Height: 512}, image.GenerateOptions{Balance: true})
+	assert.NoError(t, err)
+	c, err := New(key, WithExhaustion(ExhaustError), WithChaining())
+	assert.NoError(t, err)
+
+	// The pool is shared by all frames of a message
+	plain := strings.Repeat("abcdefghijklmnopqrstuvwxyz ", 2*ChunkSize/27)
+	ct := encryptStream(t, c, plain)
+	_, enc, err := Read(bytes.NewReader(ct))
+	assert.NoError(t, err)
+	seen := make(map[PixelPosition]bool)
+	for _, p := range enc {
+		assert.False(t, seen[p])
+		seen[p] = true
+	}
+
+	dec, err := decryptStream(c, ct)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, dec)
+
+	// More symbols than pixels
+	ew, err := c.NewEncryptWriter(&bytes.Buffer{})
+	assert.NoError(t, err)
+	_, err = ew.Write(bytes.Repeat([]byte("e"), len(key.Data)+1))
+	if err == nil {
+		err = ew.Close()
+	}
+	var exErr *ExhaustedError
+	assert.True(t, errors.As(err, &exErr))
+}
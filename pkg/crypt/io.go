@@ -1,26 +1,446 @@
 package crypt
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
-	"os"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/xvzf/htw-crypto-project/pkg/image"
 )
 
-func Read(f *os.File) ([]PixelPosition, error) {
+// Binary ciphertext format:
+//
+//	magic "HTWC" | version (1 byte) | header records | frames
+//
+// Header records are encoded as tag (1 byte), length (uvarint) and value and
+// are terminated by tagEnd. Every frame starts with the number of positions
+// (uvarint) followed by the packed coordinates; a frame with zero positions
+// terminates the ciphertext. Coordinates use the minimum bit width required
+// by the key dimension and are packed MSB first, frames are padded to full bytes.
 
-	var out []PixelPosition
+// Format describes the serialization of a ciphertext
+type Format string
+
+const (
+	// FormatJSON encodes the ciphertext as JSON array of PixelPositions
+	FormatJSON Format = "json"
+	// FormatBinary encodes the ciphertext in the compact binary container format
+	FormatBinary Format = "binary"
+)
+
+// Version is the binary ciphertext format version written by this package
+const Version uint8 = 1
+
+var magic = []byte("HTWC")
+
+// maxFrameSize limits the number of positions accepted in a single frame, the Writer never
+// emits more than ChunkSize
+const maxFrameSize = ChunkSize
+
+// Header record tags
+const (
+	tagEnd            uint8 = 0
+	tagDimension      uint8 = 1
+	tagMask           uint8 = 2
+	tagFingerprint    uint8 = 3
+	tagAuthentication uint8 = 4
+	tagChannel        uint8 = 5
+	tagNonce          uint8 = 6
+	tagChaining       uint8 = 7
+	tagFlattened      uint8 = 8
+)
+
+var (
+	// ErrInvalidFormat is returned when a ciphertext can not be parsed
+	ErrInvalidFormat = errors.New("invalid ciphertext format")
+	// ErrWrongKey is returned when a ciphertext was encrypted using a different key image
+	ErrWrongKey = errors.New("wrong key")
+)
 
-	dec := json.NewDecoder(f)
+// Header contains the metadata stored alongside a binary ciphertext
+type Header struct {
+	// Dimension of the key image
+	Dimension image.Dimension
+	// Mask applied to the key pixel values
+	Mask uint8
+	// Fingerprint of the key image, see image.Fingerprint
+	Fingerprint []byte
+	// Authenticated marks every frame to be followed by an authentication tag
+	Authenticated bool
+	// Channel used for reading the key image
+	Channel image.Channel
+	// Nonce of the message permuting the pixel coordinates, nil if disabled
+	Nonce []byte
+	// Chained marks the symbols to depend on the previously emitted position
+	Chained bool
+	// Flattened marks the homophones to be limited to a subset of every pixel group, which is
+	// only informational for decryption
+	Flattened bool
+}
+
+// Header returns the ciphertext header describing the container
+func (c *Container) Header() *Header {
+	return &Header{
+		Dimension:     c.Image.Dimension,
+		Mask:          c.Mask,
+		Fingerprint:   c.Fingerprint,
+		Authenticated: c.Authenticate,
+		Channel:       c.Image.Channel,
+		Chained:       c.Chaining,
+		Flattened:     c.Flattening != nil,
+	}
+}
 
-	if err := dec.Decode(&out); err != nil {
+// NewHeader returns the header for a new ciphertext, including a fresh nonce if enabled
+func (c *Container) NewHeader() (*Header, error) {
+	h := c.Header()
+	if c.Nonce {
+		var err error
+		if h.Nonce, err = readNonce(c.Rand); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Check verifies a ciphertext header is compatible with the container
+func (c *Container) Check(h *Header) error {
+	if h.Fingerprint != nil && !bytes.Equal(h.Fingerprint, c.Fingerprint) {
+		return fmt.Errorf("%w: ciphertext was encrypted using a different key image", ErrWrongKey)
+	}
+	if h.Dimension != c.Image.Dimension {
+		return fmt.Errorf("%w: ciphertext expects a key with dimension %dx%d, got %dx%d", ErrWrongKey,
+			h.Dimension.Width, h.Dimension.Height, c.Image.Dimension.Width, c.Image.Dimension.Height)
+	}
+	if h.Channel != c.Image.Channel {
+		return fmt.Errorf("ciphertext expects the key channel %s, got %s", h.Channel, c.Image.Channel)
+	}
+	if h.Mask != c.Mask {
+		return fmt.Errorf("ciphertext uses mask 0x%02x, container uses 0x%02x", h.Mask, c.Mask)
+	}
+	if c.Authenticate && !h.Authenticated {
+		return fmt.Errorf("%w: ciphertext is not authenticated", ErrAuthentication)
+	}
+	return nil
+}
+
+// coordinateBits returns the bit width required for addressing n values
+func coordinateBits(n int) uint {
+	if n <= 1 {
+		return 0
+	}
+	return uint(bits.Len(uint(n - 1)))
+}
+
+// Writer encodes a ciphertext frame by frame
+type Writer struct {
+	w      *bufio.Writer
+	h      *Header
+	format Format
+	n      int
+	header []byte
+	auth   *authenticator
+}
+
+// NewWriter creates a ciphertext writer, for the binary format the header is written immediately
+func NewWriter(w io.Writer, h *Header, f Format) (*Writer, error) {
+	cw := &Writer{
+		w:      bufio.NewWriter(w),
+		h:      h,
+		format: f,
+	}
+
+	switch f {
+	case FormatBinary:
+		cw.header = encodeHeader(h)
+		if _, err := cw.w.Write(cw.header); err != nil {
+			return nil, err
+		}
+	case FormatJSON:
+		if h != nil && h.Authenticated {
+			return nil, errors.New("authentication is not supported for the JSON format")
+		}
+		if h != nil && h.Nonce != nil {
+			return nil, errors.New("nonces are not supported for the JSON format")
+		}
+		if err := cw.w.WriteByte('['); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", f)
+	}
+
+	return cw, nil
+}
+
+// WriteFrame appends positions to the ciphertext
+func (w *Writer) WriteFrame(in []PixelPosition) error {
+	if len(in) == 0 {
+		return nil
+	}
+
+	if w.format == FormatJSON {
+		for _, p := range in {
+			if w.n > 0 {
+				if err := w.w.WriteByte(','); err != nil {
+					return err
+				}
+			}
+			b, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+			if _, err := w.w.Write(b); err != nil {
+				return err
+			}
+			w.n++
+		}
+		return nil
+	}
+
+	// Split into frames of at most ChunkSize positions
+	for len(in) > 0 {
+		n := len(in)
+		if n > ChunkSize {
+			n = ChunkSize
+		}
+		if err := w.writeFrame(in[:n]); err != nil {
+			return err
+		}
+		w.n += n
+		in = in[n:]
+	}
+	return nil
+}
+
+// Close terminates the ciphertext and flushes buffered data. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.format == FormatJSON {
+		if _, err := w.w.WriteString("]\n"); err != nil {
+			return err
+		}
+	} else if err := w.writeFrame(nil); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// authenticate sets the key used for computing the frame authentication tags
+func (w *Writer) authenticate(key []byte) {
+	w.auth = newAuthenticator(key, w.header)
+}
+
+// writeFrame writes a binary frame followed by its authentication tag
+func (w *Writer) writeFrame(in []PixelPosition) error {
+	raw, err := encodeFrame(w.h.Dimension, in)
+	if err != nil {
+		return err
+	}
+	if _, err := w.w.Write(raw); err != nil {
+		return err
+	}
+
+	if w.h.Authenticated {
+		if w.auth == nil {
+			return errors.New("authenticated ciphertext requires a key")
+		}
+		_, err = w.w.Write(w.auth.tag(raw, in == nil))
+	}
+	return err
+}
+
+// Reader decodes a ciphertext frame by frame, the format is detected automatically
+type Reader struct {
+	// Header of the ciphertext, nil for JSON ciphertexts
+	Header *Header
+
+	r      *bufio.Reader
+	json   *json.Decoder
+	done   bool
+	header []byte
+	auth   *authenticator
+	// unverified accepts authenticated frames without verifying their tags
+	unverified bool
+}
+
+// NewReader creates a ciphertext reader and parses the header
+func NewReader(r io.Reader) (*Reader, error) {
+	cr := &Reader{r: bufio.NewReader(r)}
+
+	if !isBinary(cr.r) {
+		cr.json = json.NewDecoder(cr.r)
+		t, err := cr.json.Token()
+		if err != nil {
+			return nil, err
+		}
+		if d, ok := t.(json.Delim); !ok || d != '[' {
+			return nil, ErrInvalidFormat
+		}
+		return cr, nil
+	}
+
+	h, raw, err := readHeader(cr.r)
+	if err != nil {
 		return nil, err
 	}
+	cr.Header = h
+	cr.header = raw
 
-	return out, nil
+	return cr, nil
+}
+
+// authenticate sets the key used for verifying the frame authentication tags
+func (r *Reader) authenticate(key []byte) {
+	r.auth = newAuthenticator(key, r.header)
 }
 
-func Write(f *os.File, in []PixelPosition) error {
+// SkipAuthentication accepts authenticated ciphertexts without verifying their tags, e.g. for
+// analyzing them without the key. The returned positions may have been tampered with.
+func (r *Reader) SkipAuthentication() {
+	r.unverified = true
+}
 
-	enc := json.NewEncoder(f)
+// Next returns the next frame of positions, io.EOF is returned after the last frame.
+// Authenticated ciphertexts require a key set by Container.DecryptFrames or Container.Read,
+// a frame is returned after its tag has been verified. Without a key ErrAuthentication is
+// returned unless SkipAuthentication has been called.
+func (r *Reader) Next() ([]PixelPosition, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+
+	if r.json != nil {
+		var out []PixelPosition
+		for len(out) < ChunkSize && r.json.More() {
+			var p PixelPosition
+			if err := r.json.Decode(&p); err != nil {
+				return nil, err
+			}
+			if p.Width < 0 || p.Height < 0 {
+				return nil, errors.New("Invalid pixel position")
+			}
+			out = append(out, p)
+		}
+		if len(out) > 0 {
+			return out, nil
+		}
+		// Closing bracket
+		if _, err := r.json.Token(); err != nil {
+			return nil, err
+		}
+		r.done = true
+		return nil, io.EOF
+	}
+
+	raw, err := readFrame(r.r, r.Header.Dimension)
+	if err != nil {
+		return nil, err
+	}
+	final := raw[0] == 0
+
+	if r.Header.Authenticated {
+		tag := make([]byte, TagSize)
+		if _, err := io.ReadFull(r.r, tag); err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if r.auth == nil && !r.unverified {
+			return nil, fmt.Errorf("%w: a key is required for verifying the ciphertext", ErrAuthentication)
+		}
+		if r.auth != nil && !r.auth.verify(raw, final, tag) {
+			return nil, ErrAuthentication
+		}
+	}
+
+	if final {
+		r.done = true
+		return nil, io.EOF
+	}
+
+	return decodeFrame(raw, r.Header.Dimension)
+}
+
+// Read parses a ciphertext, the format (JSON or binary) is detected automatically.
+// The returned header is nil for JSON ciphertexts. Authenticated ciphertexts are rejected,
+// they have to be read using Container.Read.
+func Read(r io.Reader) (*Header, []PixelPosition, error) {
+	cr, err := NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return readAll(cr)
+}
+
+// Read parses a ciphertext encrypted using the container, the header is checked and the
+// tags of authenticated ciphertexts are verified
+func (c *Container) Read(r io.Reader) (*Header, []PixelPosition, error) {
+	cr, err := NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.verify(cr); err != nil {
+		return nil, nil, err
+	}
+	return readAll(cr)
+}
+
+// verify checks the header of cr and sets the key for verifying its tags
+func (c *Container) verify(cr *Reader) error {
+	if cr.Header == nil {
+		if c.Authenticate {
+			return fmt.Errorf("%w: ciphertext is not authenticated", ErrAuthentication)
+		}
+		return nil
+	}
+	if err := c.Check(cr.Header); err != nil {
+		return err
+	}
+	if cr.Header.Authenticated {
+		cr.authenticate(c.authKey)
+	}
+	return nil
+}
+
+// readAll returns all remaining positions of cr
+func readAll(cr *Reader) (*Header, []PixelPosition, error) {
+	var out []PixelPosition
+	for {
+		frame, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		out = append(out, frame...)
+	}
+
+	return cr.Header, out, nil
+}
+
+// Write encodes a ciphertext in the binary format
+func Write(w io.Writer, h *Header, in []PixelPosition) error {
+	cw, err := NewWriter(w, h, FormatBinary)
+	if err != nil {
+		return err
+	}
+	if err := cw.WriteFrame(in); err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+// WriteJSON encodes a ciphertext as JSON array
+func WriteJSON(w io.Writer, in []PixelPosition) error {
+
+	enc := json.NewEncoder(w)
 
 	if err := enc.Encode(in); err != nil {
 		return err
@@ -28,3 +448,263 @@ func Write(f *os.File, in []PixelPosition) error {
 
 	return nil
 }
+
+// isBinary checks for the binary format magic without consuming input
+func isBinary(br *bufio.Reader) bool {
+	m, err := br.Peek(len(magic))
+	return err == nil && bytes.Equal(m, magic)
+}
+
+func encodeHeader(h *Header) []byte {
+	var b bytes.Buffer
+	b.Write(magic)
+	b.WriteByte(Version)
+
+	dim := make([]byte, 0, 2*binary.MaxVarintLen64)
+	dim = appendUvarint(dim, uint64(h.Dimension.Width))
+	dim = appendUvarint(dim, uint64(h.Dimension.Height))
+	writeRecord(&b, tagDimension, dim)
+	writeRecord(&b, tagMask, []byte{h.Mask})
+	if h.Fingerprint != nil {
+		writeRecord(&b, tagFingerprint, h.Fingerprint)
+	}
+	if h.Authenticated {
+		writeRecord(&b, tagAuthentication, []byte{authHMACSHA256})
+	}
+	if h.Channel != image.ChannelDefault {
+		writeRecord(&b, tagChannel, []byte{uint8(h.Channel)})
+	}
+	if h.Nonce != nil {
+		writeRecord(&b, tagNonce, h.Nonce)
+	}
+	if h.Chained {
+		writeRecord(&b, tagChaining, []byte{chainingCTR})
+	}
+	if h.Flattened {
+		writeRecord(&b, tagFlattened, nil)
+	}
+
+	b.WriteByte(tagEnd)
+	return b.Bytes()
+}
+
+func writeRecord(b *bytes.Buffer, tag uint8, value []byte) {
+	b.WriteByte(tag)
+	b.Write(appendUvarint(nil, uint64(len(value))))
+	b.Write(value)
+}
+
+// recordingReader keeps a copy of everything read, used for authenticating the raw header
+type recordingReader struct {
+	r   *bufio.Reader
+	buf bytes.Buffer
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf.Write(p[:n])
+	return n, err
+}
+
+func (r *recordingReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.buf.WriteByte(b)
+	}
+	return b, err
+}
+
+// readHeader parses the header, the raw header bytes are returned alongside
+func readHeader(br *bufio.Reader) (*Header, []byte, error) {
+	r := &recordingReader{r: br}
+
+	if _, err := io.ReadFull(r, make([]byte, len(magic))); err != nil {
+		return nil, nil, err
+	}
+	v, err := r.ReadByte()
+	if err != nil {
+		return nil, nil, err
+	}
+	if v != Version {
+		return nil, nil, fmt.Errorf("unsupported ciphertext version %d", v)
+	}
+
+	h := &Header{}
+	for {
+		tag, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		if tag == tagEnd {
+			break
+		}
+
+		l, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		if l > 1<<16 {
+			return nil, nil, ErrInvalidFormat
+		}
+		value := make([]byte, l)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, nil, err
+		}
+
+		switch tag {
+		case tagDimension:
+			vr := bytes.NewReader(value)
+			width, err := binary.ReadUvarint(vr)
+			if err != nil {
+				return nil, nil, ErrInvalidFormat
+			}
+			height, err := binary.ReadUvarint(vr)
+			if err != nil {
+				return nil, nil, ErrInvalidFormat
+			}
+			if width > 1<<31 || height > 1<<31 {
+				return nil, nil, ErrInvalidFormat
+			}
+			h.Dimension = image.Dimension{Width: int(width), Height: int(height)}
+		case tagMask:
+			if len(value) != 1 {
+				return nil, nil, ErrInvalidFormat
+			}
+			h.Mask = value[0]
+		case tagFingerprint:
+			if len(value) != image.FingerprintSize {
+				return nil, nil, ErrInvalidFormat
+			}
+			h.Fingerprint = value
+		case tagAuthentication:
+			if len(value) != 1 || value[0] != authHMACSHA256 {
+				return nil, nil, fmt.Errorf("unsupported ciphertext authentication %v", value)
+			}
+			h.Authenticated = true
+		case tagChannel:
+			if len(value) != 1 || image.Channel(value[0]) > image.ChannelAll {
+				return nil, nil, ErrInvalidFormat
+			}
+			h.Channel = image.Channel(value[0])
+		case tagNonce:
+			if len(value) != NonceSize {
+				return nil, nil, ErrInvalidFormat
+			}
+			h.Nonce = value
+		case tagChaining:
+			if len(value) != 1 || value[0] != chainingCTR {
+				return nil, nil, fmt.Errorf("unsupported ciphertext chaining %v", value)
+			}
+			h.Chained = true
+		case tagFlattened:
+			if len(value) != 0 {
+				return nil, nil, ErrInvalidFormat
+			}
+			h.Flattened = true
+		default:
+			// Unknown records may change the ciphertext semantics -> reject them
+			return nil, nil, fmt.Errorf("unknown ciphertext header record %d", tag)
+		}
+	}
+
+	return h, r.buf.Bytes(), nil
+}
+
+// encodeFrame packs the positions of a frame, nil encodes the terminating frame
+func encodeFrame(dim image.Dimension, in []PixelPosition) ([]byte, error) {
+	bw, bh := coordinateBits(dim.Width), coordinateBits(dim.Height)
+
+	out := make([]byte, 0, binary.MaxVarintLen64+(len(in)*int(bw+bh)+7)/8)
+	out = appendUvarint(out, uint64(len(in)))
+
+	var acc uint64
+	var n uint
+	for _, p := range in {
+		if p.Width < 0 || p.Width >= dim.Width || p.Height < 0 || p.Height >= dim.Height {
+			return nil, errors.New("Invalid pixel position")
+		}
+		for _, v := range [2]struct {
+			value uint64
+			bits  uint
+		}{{uint64(p.Width), bw}, {uint64(p.Height), bh}} {
+			acc = acc<<v.bits | v.value
+			n += v.bits
+			for n >= 8 {
+				n -= 8
+				out = append(out, byte(acc>>n))
+			}
+		}
+	}
+	// Pad to full bytes
+	if n > 0 {
+		out = append(out, byte(acc<<(8-n)))
+	}
+
+	return out, nil
+}
+
+// readFrame reads a raw frame, a frame with zero positions terminates the ciphertext
+func readFrame(r *bufio.Reader, dim image.Dimension) ([]byte, error) {
+	bw, bh := coordinateBits(dim.Width), coordinateBits(dim.Height)
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if count > maxFrameSize {
+		return nil, ErrInvalidFormat
+	}
+
+	raw := appendUvarint(nil, count)
+	payload := len(raw)
+	raw = append(raw, make([]byte, (count*uint64(bw+bh)+7)/8)...)
+	if _, err := io.ReadFull(r, raw[payload:]); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// decodeFrame unpacks the positions of a raw frame
+func decodeFrame(raw []byte, dim image.Dimension) ([]PixelPosition, error) {
+	bw, bh := coordinateBits(dim.Width), coordinateBits(dim.Height)
+
+	count, l := binary.Uvarint(raw)
+	if l <= 0 {
+		return nil, ErrInvalidFormat
+	}
+	raw = raw[l:]
+
+	out := make([]PixelPosition, count)
+	var acc uint64
+	var n uint
+	next := func(bits uint) int {
+		for n < bits {
+			acc = acc<<8 | uint64(raw[0])
+			raw = raw[1:]
+			n += 8
+		}
+		n -= bits
+		v := (acc >> n) & (1<<bits - 1)
+		return int(v)
+	}
+	for i := range out {
+		out[i] = PixelPosition{Width: next(bw), Height: next(bh)}
+		if out[i].Width >= dim.Width || out[i].Height >= dim.Height {
+			return nil, errors.New("Invalid pixel position")
+		}
+	}
+
+	return out, nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
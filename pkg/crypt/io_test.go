@@ -1,11 +1,14 @@
 package crypt
 
 import (
+	"bytes"
+	"errors"
 	"io/ioutil"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
 )
 
 func TestWrite(t *testing.T) {
@@ -13,7 +16,7 @@ func TestWrite(t *testing.T) {
 	assert.NoError(t, err)
 	defer f.Close()
 
-	err = Write(f, testData1MByteEnc)
+	err = Write(f, cipher.Header(), testData1MByteEnc)
 	assert.NoError(t, err)
 }
 
@@ -21,13 +24,128 @@ func TestRead(t *testing.T) {
 	f, err := ioutil.TempFile("/tmp", "crypt_io_write")
 	assert.NoError(t, err)
 
-	err = Write(f, testData1MByteEnc)
+	err = Write(f, cipher.Header(), testData1MByteEnc)
 	assert.NoError(t, err)
 	f.Close()
 
 	f, err = os.Open(f.Name())
 	assert.NoError(t, err)
 
-	v, err := Read(f)
+	h, v, err := Read(f)
+	assert.NoError(t, err)
+	assert.Equal(t, cipher.Header(), h)
 	assert.Equal(t, testData1MByteEnc, v)
 }
+
+func TestReadJSON(t *testing.T) {
+	f, err := ioutil.TempFile("/tmp", "crypt_io_write_json")
+	assert.NoError(t, err)
+
+	err = WriteJSON(f, testData1MByteEnc)
+	assert.NoError(t, err)
+	f.Close()
+
+	f, err = os.Open(f.Name())
+	assert.NoError(t, err)
+
+	h, v, err := Read(f)
+	assert.NoError(t, err)
+	assert.Nil(t, h)
+	assert.Equal(t, testData1MByteEnc, v)
+}
+
+func TestWrite_Packed(t *testing.T) {
+	for _, dim := range []image.Dimension{{Width: 1, Height: 1}, {Width: 3, Height: 5}, {Width: 128, Height: 128}, {Width: 1000, Height: 7}} {
+		h := &Header{Dimension: dim, Mask: image.MaskASCII}
+
+		var in []PixelPosition
+		for w := 0; w < dim.Width; w++ {
+			for h := 0; h < dim.Height; h++ {
+				in = append(in, PixelPosition{w, h})
+			}
+		}
+
+		var buf bytes.Buffer
+		assert.NoError(t, Write(&buf, h, in))
+
+		// Coordinates use the minimum bit width
+		bits := int(coordinateBits(dim.Width) + coordinateBits(dim.Height))
+		assert.Less(t, buf.Len(), len(in)*bits/8+32)
+
+		rh, out, err := Read(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, h, rh)
+		assert.Equal(t, in, out)
+	}
+}
+
+func TestWrite_InvalidPosition(t *testing.T) {
+	var buf bytes.Buffer
+	h := &Header{Dimension: image.Dimension{Width: 4, Height: 4}}
+	assert.Error(t, Write(&buf, h, []PixelPosition{{4, 0}}))
+}
+
+func TestRead_Truncated(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, cipher.Header(), testData1MByteEnc[:100]))
+
+	_, _, err := Read(bytes.NewReader(buf.Bytes()[:buf.Len()-10]))
+	assert.Error(t, err)
+}
+
+func TestRead_FrameSize(t *testing.T) {
+	// A frame header announcing more than ChunkSize positions is rejected before decoding
+	h := cipher.Header()
+	var buf bytes.Buffer
+	buf.Write(encodeHeader(h))
+	buf.Write(appendUvarint(nil, ChunkSize+1))
+	buf.Write(make([]byte, 1<<20))
+
+	_, _, err := Read(&buf)
+	assert.Equal(t, ErrInvalidFormat, err)
+}
+
+// failingWriter fails once more than n bytes have been written
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, errors.New("write failed")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestWriter_JSONError(t *testing.T) {
+	// Write errors are reported instead of silently truncating the JSON
+	for _, n := range []int{1, 4096} {
+		cw, err := NewWriter(&failingWriter{n: n}, nil, FormatJSON)
+		assert.NoError(t, err)
+		err = cw.WriteFrame(testData1MByteEnc[:1000])
+		if err == nil {
+			err = cw.Close()
+		}
+		assert.Error(t, err)
+	}
+}
+
+func TestRead_Header(t *testing.T) {
+	h := &Header{
+		Dimension:     image.Dimension{Width: 30, Height: 20},
+		Mask:          image.MaskByte,
+		Fingerprint:   make([]byte, image.FingerprintSize),
+		Authenticated: false,
+		Channel:       image.ChannelLuminance,
+		Chained:       true,
+		Flattened:     true,
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, h, []PixelPosition{{29, 19}}))
+
+	rh, _, err := Read(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, h, rh)
+}
@@ -0,0 +1,157 @@
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+// Ledger records the pixels of a key image consumed by previous messages, which allows
+// using a key pad-like: no pixel is ever used twice. A Ledger is not safe for concurrent use.
+//
+// Binary format:
+//
+//	magic "HTWP" | version | fingerprint | uvarint width | uvarint height | bitmap
+//
+// The bitmap contains one bit per pixel index w + width*h, least significant bit first.
+type Ledger struct {
+	Fingerprint []byte
+	Dimension   image.Dimension
+	used        []byte
+	count       int
+}
+
+var ledgerMagic = []byte("HTWP")
+
+const ledgerVersion uint8 = 1
+
+// MaxLedgerPixels bounds the key dimension of a ledger, its bitmap takes up to 512 MiB
+const MaxLedgerPixels = 1 << 32
+
+// ErrInvalidLedger is returned when a ledger can not be parsed
+var ErrInvalidLedger = errors.New("invalid ledger format")
+
+// WithLedger samples the pixel groups without reusing any pixel recorded in the ledger and
+// records every used pixel. Exhausted groups always return an ExhaustedError.
+func WithLedger(l *Ledger) Option {
+	return func(c *Container) {
+		c.Ledger = l
+	}
+}
+
+// NewLedger returns an empty ledger for the key image, which may have at most MaxLedgerPixels
+func NewLedger(i *image.Image) (*Ledger, error) {
+	if uint64(i.Dimension.Width)*uint64(i.Dimension.Height) > MaxLedgerPixels {
+		return nil, fmt.Errorf("key dimension %dx%d exceeds %d pixels", i.Dimension.Width, i.Dimension.Height, uint64(MaxLedgerPixels))
+	}
+	return &Ledger{
+		Fingerprint: image.Fingerprint(i),
+		Dimension:   i.Dimension,
+		used:        make([]byte, (i.Dimension.Width*i.Dimension.Height+7)/8),
+	}, nil
+}
+
+// check verifies the ledger belongs to the key image
+func (l *Ledger) check(i *image.Image) error {
+	if !bytes.Equal(l.Fingerprint, image.Fingerprint(i)) || l.Dimension != i.Dimension {
+		return fmt.Errorf("%w: ledger belongs to a different key image", ErrWrongKey)
+	}
+	return nil
+}
+
+// Used reports whether the pixel has been consumed
+func (l *Ledger) Used(p PixelPosition) bool {
+	idx := p.Width + l.Dimension.Width*p.Height
+	return l.used[idx/8]&(1<<(idx%8)) != 0
+}
+
+// Use records the pixels as consumed
+func (l *Ledger) Use(enc []PixelPosition) {
+	for _, p := range enc {
+		idx := p.Width + l.Dimension.Width*p.Height
+		if l.used[idx/8]&(1<<(idx%8)) == 0 {
+			l.used[idx/8] |= 1 << (idx % 8)
+			l.count++
+		}
+	}
+}
+
+// Consumed returns the number of consumed pixels
+func (l *Ledger) Consumed() int {
+	return l.count
+}
+
+// Remaining returns the number of unused pixels per symbol of the container
+func (c *Container) Remaining() map[uint8]int {
+	r := make(map[uint8]int, len(c.homophones))
+	for v, g := range c.homophones {
+		for _, p := range g {
+			if c.Ledger == nil || !c.Ledger.Used(p) {
+				r[v]++
+			}
+		}
+	}
+	return r
+}
+
+// Save writes the ledger in its binary format
+func (l *Ledger) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.Write(ledgerMagic)
+	bw.WriteByte(ledgerVersion)
+	bw.Write(l.Fingerprint)
+	bw.Write(appendUvarint(nil, uint64(l.Dimension.Width)))
+	bw.Write(appendUvarint(nil, uint64(l.Dimension.Height)))
+	bw.Write(l.used)
+	return bw.Flush()
+}
+
+// LoadLedger reads a ledger written by Save
+func LoadLedger(r io.Reader) (*Ledger, error) {
+	br := bufio.NewReader(r)
+
+	head := make([]byte, len(ledgerMagic)+1+image.FingerprintSize)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, ErrInvalidLedger
+	}
+	if !bytes.Equal(head[:len(ledgerMagic)], ledgerMagic) || head[len(ledgerMagic)] != ledgerVersion {
+		return nil, ErrInvalidLedger
+	}
+
+	width, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, ErrInvalidLedger
+	}
+	height, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, ErrInvalidLedger
+	}
+	if height > 0 && width > MaxLedgerPixels/height {
+		return nil, ErrInvalidLedger
+	}
+
+	l := &Ledger{
+		Fingerprint: head[len(ledgerMagic)+1:],
+		Dimension:   image.Dimension{Width: int(width), Height: int(height)},
+		used:        make([]byte, (width*height+7)/8),
+	}
+	if _, err := io.ReadFull(br, l.used); err != nil {
+		return nil, ErrInvalidLedger
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		return nil, ErrInvalidLedger
+	}
+
+	for _, b := range l.used {
+		for ; b != 0; b &= b - 1 {
+			l.count++
+		}
+	}
+
+	return l, nil
+}
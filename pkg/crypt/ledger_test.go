@@ -0,0 +1,75 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+func TestLedger(t *testing.T) {
+	l, err := NewLedger(cipher.Image)
+	assert.NoError(t, err)
+	c, err := New(cipher.Image, WithLedger(l))
+	assert.NoError(t, err)
+	remaining := c.Remaining()
+	group := remaining['e']
+	assert.Equal(t, len(cipher.PixelGroups['e']), group)
+
+	// Pixels are never reused across messages
+	enc1, err := c.Encrypt(strings.Repeat("e", group/2))
+	assert.NoError(t, err)
+	enc2, err := c.Encrypt(strings.Repeat("e", group-group/2))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, cipher.PixelGroups['e'], append(enc1, enc2...))
+	assert.Equal(t, group, l.Consumed())
+	assert.Equal(t, 0, c.Remaining()['e'])
+	assert.Equal(t, remaining['a'], c.Remaining()['a'])
+
+	_, err = c.Encrypt("e")
+	var exErr *ExhaustedError
+	assert.True(t, errors.As(err, &exErr))
+
+	// Round trip
+	var buf bytes.Buffer
+	assert.NoError(t, l.Save(&buf))
+	loaded, err := LoadLedger(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, l, loaded)
+
+	_, err = LoadLedger(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.Equal(t, ErrInvalidLedger, err)
+	_, err = LoadLedger(strings.NewReader("HTWC"))
+	assert.Equal(t, ErrInvalidLedger, err)
+
+	// The ledger belongs to a single key
+	_, err = New(image.Mock(), WithLedger(l))
+	assert.True(t, errors.Is(err, ErrWrongKey))
+}
+
+func TestLedger_Dimension(t *testing.T) {
+	// Every ledger which can be created can be loaded again
+	wide := &image.Image{Data: make([]uint8, 70000*2), Dimension: image.Dimension{Width: 70000, Height: 2}}
+	l, err := NewLedger(wide)
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	assert.NoError(t, l.Save(&buf))
+	loaded, err := LoadLedger(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, l, loaded)
+
+	_, err = NewLedger(&image.Image{Dimension: image.Dimension{Width: 1 << 20, Height: 1<<12 + 1}})
+	assert.Error(t, err)
+
+	buf.Reset()
+	buf.Write(ledgerMagic)
+	buf.WriteByte(ledgerVersion)
+	buf.Write(make([]byte, image.FingerprintSize))
+	buf.Write(appendUvarint(nil, 1<<32))
+	buf.Write(appendUvarint(nil, 1<<32))
+	_, err = LoadLedger(&buf)
+	assert.Equal(t, ErrInvalidLedger, err)
+}
@@ -0,0 +1,168 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+// Messages encrypted with a nonce permute the pixel coordinates using a keyed permutation
+// of the index space w + width*h:
+//
+//	key = HMAC-SHA256(image.Hash(key image), nonce)[:16]
+//
+// The permutation is a balanced Feistel network with AES-128 as round function, indices
+// outside of the image are mapped back using cycle walking. The same position therefore
+// represents different pixels in every message.
+
+// NonceSize is the length of a message nonce in bytes
+const NonceSize = 16
+
+// feistelRounds is the number of rounds of the coordinate permutation
+const feistelRounds = 8
+
+// WithNonce enables a random nonce per message, which permutes the pixel coordinates.
+// Encryptions of the same plaintext no longer share positions.
+func WithNonce() Option {
+	return func(c *Container) {
+		c.Nonce = true
+	}
+}
+
+// permutationKey derives the secret mixed with every nonce from the key image
+func permutationKey(i *image.Image) []byte {
+	return image.Hash(i, "htw-crypto-project permutation key")
+}
+
+// NewNonce returns a random message nonce
+func NewNonce() ([]byte, error) {
+	return readNonce(rand.Reader)
+}
+
+// readNonce reads a message nonce from r
+func readNonce(r io.Reader) ([]byte, error) {
+	nonce := make([]byte, NonceSize)
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// permutation is a keyed bijection of the pixel positions of an image
+type permutation struct {
+	dim  image.Dimension
+	n    uint64
+	half uint
+	mask uint64
+	// block is the AES cipher of the message key
+	block interface{ Encrypt(dst, src []byte) }
+}
+
+func (c *Container) permutation(nonce []byte) (*permutation, error) {
+	if len(nonce) != NonceSize {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	mac := hmac.New(sha256.New, c.permKey)
+	mac.Write(nonce)
+	block, err := aes.NewCipher(mac.Sum(nil)[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	dim := c.Image.Dimension
+	n := uint64(dim.Width) * uint64(dim.Height)
+	// Both halves of the Feistel network have the same width
+	half := (coordinateBits(int(n)) + 1) / 2
+
+	return &permutation{
+		dim:   dim,
+		n:     n,
+		half:  half,
+		mask:  1<<half - 1,
+		block: block,
+	}, nil
+}
+
+// round is the Feistel round function
+func (p *permutation) round(r int, v uint64) uint64 {
+	var buf [aes.BlockSize]byte
+	buf[0] = byte(r)
+	binary.BigEndian.PutUint64(buf[1:], v)
+	p.block.Encrypt(buf[:], buf[:])
+	return binary.BigEndian.Uint64(buf[:8]) & p.mask
+}
+
+func (p *permutation) forward(x uint64) uint64 {
+	for {
+		l, r := x>>p.half, x&p.mask
+		for i := 0; i < feistelRounds; i++ {
+			l, r = r, l^p.round(i, r)
+		}
+		x = l<<p.half | r
+
+		// Cycle walking until the index is part of the image
+		if x < p.n {
+			return x
+		}
+	}
+}
+
+func (p *permutation) inverse(x uint64) uint64 {
+	for {
+		l, r := x>>p.half, x&p.mask
+		for i := feistelRounds - 1; i >= 0; i-- {
+			l, r = r^p.round(i, l), l
+		}
+		x = l<<p.half | r
+
+		if x < p.n {
+			return x
+		}
+	}
+}
+
+// apply maps the positions in place using f
+func (p *permutation) apply(enc []PixelPosition, f func(uint64) uint64) error {
+	w := uint64(p.dim.Width)
+	for i, ec := range enc {
+		if ec.Width < 0 || ec.Height < 0 || ec.Width >= p.dim.Width || ec.Height >= p.dim.Height {
+			return errors.New("Invalid pixel position")
+		}
+		x := f(uint64(ec.Width) + w*uint64(ec.Height))
+		enc[i] = PixelPosition{Width: int(x % w), Height: int(x / w)}
+	}
+	return nil
+}
+
+// EncryptNonce encrypts a message and permutes the pixel coordinates using the nonce
+func (c *Container) EncryptNonce(s string, nonce []byte) (Encrypted, error) {
+	p, err := c.permutation(nonce)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := c.Encrypt(s)
+	if err != nil {
+		return nil, err
+	}
+	return enc, p.apply(enc, p.forward)
+}
+
+// DecryptNonce inverts the coordinate permutation of the nonce and decrypts the message
+func (c *Container) DecryptNonce(enc Encrypted, nonce []byte) (string, error) {
+	p, err := c.permutation(nonce)
+	if err != nil {
+		return "", err
+	}
+	plain := append(Encrypted{}, enc...)
+	if err := p.apply(plain, p.inverse); err != nil {
+		return "", err
+	}
+	return c.Decrypt(plain)
+}
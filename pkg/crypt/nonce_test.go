@@ -0,0 +1,100 @@
+package crypt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+func TestPermutation(t *testing.T) {
+	nonce, err := NewNonce()
+	assert.NoError(t, err)
+
+	for _, dim := range []image.Dimension{{Width: 1, Height: 1}, {Width: 7, Height: 5}, {Width: 64, Height: 33}} {
+		c := &Container{Image: &image.Image{Dimension: dim}, permKey: []byte("key")}
+		p, err := c.permutation(nonce)
+		assert.NoError(t, err)
+
+		// Bijection on the image indices
+		n := uint64(dim.Width * dim.Height)
+		seen := make(map[uint64]bool)
+		for x := uint64(0); x < n; x++ {
+			y := p.forward(x)
+			assert.Less(t, y, n)
+			assert.False(t, seen[y])
+			seen[y] = true
+			assert.Equal(t, x, p.inverse(y))
+		}
+	}
+
+	_, err = cipher.permutation([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestContainer_EncryptNonce(t *testing.T) {
+	plain := "The quick brown fox jumps over the lazy dog"
+
+	n1, err := NewNonce()
+	assert.NoError(t, err)
+	n2, err := NewNonce()
+	assert.NoError(t, err)
+
+	enc, err := cipher.EncryptNonce(plain, n1)
+	assert.NoError(t, err)
+	dec, err := cipher.DecryptNonce(enc, n1)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, dec)
+
+	// Positions depend on the nonce
+	other, err := cipher.DecryptNonce(enc, n2)
+	assert.NoError(t, err)
+	assert.NotEqual(t, plain, other)
+
+	_, err = cipher.DecryptNonce(Encrypted{{Width: -1}}, n1)
+	assert.Error(t, err)
+}
+
+func TestNonce_Stream(t *testing.T) {
+	c, err := New(cipher.Image, WithNonce(), WithAuthentication())
+	assert.NoError(t, err)
+
+	plain := string(bytes.Repeat([]byte("abc"), ChunkSize))
+	ct1 := encryptStream(t, c, plain)
+	ct2 := encryptStream(t, c, plain)
+
+	h1, enc1, err := c.Read(bytes.NewReader(ct1))
+	assert.NoError(t, err)
+	h2, enc2, err := c.Read(bytes.NewReader(ct2))
+	assert.NoError(t, err)
+	assert.Len(t, h1.Nonce, NonceSize)
+	assert.NotEqual(t, h1.Nonce, h2.Nonce)
+
+	// The same positions no longer represent the same symbols across messages
+	dec, err := cipher.Decrypt(enc1)
+	assert.NoError(t, err)
+	assert.NotEqual(t, plain, dec)
+	shared := 0
+	for i := range enc1 {
+		if enc1[i] == enc2[i] {
+			shared++
+		}
+	}
+	assert.Less(t, shared, len(enc1)/100)
+
+	// Decryption inverts the permutation, with or without the option
+	for _, d := range []*Container{c, cipher} {
+		dec, err := decryptStream(d, ct1)
+		assert.NoError(t, err)
+		assert.Equal(t, plain, dec)
+	}
+
+	// JSON ciphertexts have no header for the nonce
+	c, err = New(cipher.Image, WithNonce())
+	assert.NoError(t, err)
+	h, err := c.NewHeader()
+	assert.NoError(t, err)
+	_, err = NewWriter(&bytes.Buffer{}, h, FormatJSON)
+	assert.Error(t, err)
+}
@@ -0,0 +1,13 @@
+package crypt
+
+import (
+	"io"
+)
+
+// WithRand replaces the CSPRNG by r, e.g. a seeded drbg.Reader for reproducible tests.
+// A predictable source makes the ciphertexts predictable as well.
+func WithRand(r io.Reader) Option {
+	return func(c *Container) {
+		c.Rand = r
+	}
+}
@@ -0,0 +1,44 @@
+package crypt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/drbg"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+// errReader fails every read
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestWithRand(t *testing.T) {
+	key, err := image.Generate(image.Dimension{Width: 64, Height: 64}, image.GenerateOptions{Rand: drbg.New([]byte("key"))})
+	assert.NoError(t, err)
+	other, err := image.Generate(image.Dimension{Width: 64, Height: 64}, image.GenerateOptions{Rand: drbg.New([]byte("key"))})
+	assert.NoError(t, err)
+	assert.Equal(t, key, other)
+
+	encrypt := func(seed string) []byte {
+		c, err := New(key, WithRand(drbg.New([]byte(seed))), WithNonce(), WithFlattening(English()))
+		assert.NoError(t, err)
+		return encryptStream(t, c, "the quick brown fox jumps over the lazy dog")
+	}
+
+	// The same seed yields the same nonce, homophone subsets and positions
+	assert.Equal(t, encrypt("seed"), encrypt("seed"))
+	assert.NotEqual(t, encrypt("seed"), encrypt("other"))
+
+	// Errors of the random source are returned
+	c, err := New(key, WithRand(errReader{errors.New("no entropy")}))
+	assert.NoError(t, err)
+	_, err = c.Encrypt("a")
+	assert.Error(t, err)
+	c.Nonce = true
+	_, err = c.NewHeader()
+	assert.Error(t, err)
+}
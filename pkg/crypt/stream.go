@@ -0,0 +1,227 @@
+package crypt
+
+import (
+	"errors"
+	"io"
+)
+
+// ChunkSize is the number of plaintext bytes encrypted into a single ciphertext frame
+const ChunkSize = 64 * 1024
+
+// encryptWriter encrypts plaintext chunk-wise into a ciphertext Writer
+type encryptWriter struct {
+	c      *Container
+	w      *Writer
+	buf    []byte
+	offset int
+	perm   *permutation
+	msg    *message
+	err    error
+}
+
+// NewEncryptWriter returns a writer encrypting everything written to it into a binary
+// ciphertext on w. Close must be called to terminate the ciphertext.
+func (c *Container) NewEncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	h, err := c.NewHeader()
+	if err != nil {
+		return nil, err
+	}
+	cw, err := NewWriter(w, h, FormatBinary)
+	if err != nil {
+		return nil, err
+	}
+	return c.EncryptFrames(cw), nil
+}
+
+// EncryptFrames returns a writer encrypting everything written to it into frames of cw.
+// Closing the returned writer closes cw.
+func (c *Container) EncryptFrames(cw *Writer) io.WriteCloser {
+	e := &encryptWriter{
+		c:   c,
+		w:   cw,
+		buf: make([]byte, 0, ChunkSize),
+	}
+	if cw.h != nil && cw.h.Authenticated {
+		cw.authenticate(c.authKey)
+	}
+	if cw.h != nil && cw.h.Nonce != nil {
+		e.perm, e.err = c.permutation(cw.h.Nonce)
+	}
+	// The selection state is carried across frames
+	if cw.h != nil {
+		e.msg = c.newMessage(cw.h.Chained)
+	} else {
+		e.msg = c.newMessage(c.Chaining)
+	}
+	return e
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		l := cap(e.buf) - len(e.buf)
+		if l > len(p) {
+			l = len(p)
+		}
+		e.buf = append(e.buf, p[:l]...)
+		p = p[l:]
+		n += l
+
+		if len(e.buf) == cap(e.buf) {
+			if err := e.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// flush encrypts the buffered plaintext and writes it as a frame
+func (e *encryptWriter) flush() error {
+	enc, err := e.c.encrypt(string(e.buf), e.msg)
+	if err != nil {
+		// Report the offset within the whole stream
+		var ubErr *UnrepresentableByteError
+		if errors.As(err, &ubErr) {
+			ubErr.Offset += e.offset
+		}
+		var exErr *ExhaustedError
+		if errors.As(err, &exErr) {
+			exErr.Offset += e.offset
+		}
+		e.err = err
+		return err
+	}
+	if e.perm != nil {
+		if err := e.perm.apply(enc, e.perm.forward); err != nil {
+			e.err = err
+			return err
+		}
+	}
+
+	if err := e.w.WriteFrame(enc); err != nil {
+		e.err = err
+		return err
+	}
+
+	e.offset += len(e.buf)
+	e.buf = e.buf[:0]
+	return nil
+}
+
+func (e *encryptWriter) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.buf) > 0 {
+		if err := e.flush(); err != nil {
+			return err
+		}
+	}
+	return e.w.Close()
+}
+
+// decryptReader decrypts a ciphertext Reader frame by frame
+type decryptReader struct {
+	c     *Container
+	r     *Reader
+	perm  *permutation
+	chain *chainer
+	buf   []byte
+}
+
+// NewDecryptReader returns a reader decrypting the ciphertext (binary or JSON) read from r
+func (c *Container) NewDecryptReader(r io.Reader) (io.Reader, error) {
+	cr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return c.DecryptFrames(cr)
+}
+
+// NewVerifiedDecryptReader verifies the whole ciphertext read from r before seeking back and
+// decrypting it, no plaintext of a modified or truncated authenticated ciphertext is released
+func (c *Container) NewVerifiedDecryptReader(r io.ReadSeeker) (io.Reader, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Verify(r); err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return c.NewDecryptReader(r)
+}
+
+// Verify reads the whole ciphertext and checks its header and authentication tags without
+// decrypting it
+func (c *Container) Verify(r io.Reader) error {
+	cr, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	if err := c.verify(cr); err != nil {
+		return err
+	}
+	for {
+		if _, err := cr.Next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// DecryptFrames returns a reader decrypting the frames of cr. The plaintext of an authenticated
+// frame is released once its tag has been verified, a truncated ciphertext is only detected
+// at its end: the output read so far has to be discarded if Read fails. NewVerifiedDecryptReader
+// verifies the whole ciphertext first.
+func (c *Container) DecryptFrames(cr *Reader) (io.Reader, error) {
+	if err := c.verify(cr); err != nil {
+		return nil, err
+	}
+	d := &decryptReader{c: c, r: cr}
+	if cr.Header == nil {
+		d.chain = c.newChainer(c.Chaining)
+	} else {
+		d.chain = c.newChainer(cr.Header.Chained)
+		if cr.Header.Nonce != nil {
+			var err error
+			if d.perm, err = c.permutation(cr.Header.Nonce); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		frame, err := d.r.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		if d.perm != nil {
+			if err := d.perm.apply(frame, d.perm.inverse); err != nil {
+				return 0, err
+			}
+		}
+		dec, err := d.c.decrypt(frame, d.chain)
+		if err != nil {
+			return 0, err
+		}
+		d.buf = []byte(dec)
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,89 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+)
+
+func TestContainer_EncryptWriter_DecryptReader(t *testing.T) {
+	var buf bytes.Buffer
+
+	ew, err := cipher.NewEncryptWriter(&buf)
+	assert.NoError(t, err)
+
+	// Write in odd sized pieces spanning multiple chunks
+	src := strings.NewReader(testData1MByte)
+	_, err = io.CopyBuffer(ew, src, make([]byte, 12345))
+	assert.NoError(t, err)
+	assert.NoError(t, ew.Close())
+
+	dr, err := cipher.NewDecryptReader(&buf)
+	assert.NoError(t, err)
+
+	dec, err := ioutil.ReadAll(dr)
+	assert.NoError(t, err)
+	assert.Equal(t, testData1MByte, string(dec))
+}
+
+func TestContainer_EncryptFrames_JSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	cw, err := NewWriter(&buf, cipher.Header(), FormatJSON)
+	assert.NoError(t, err)
+	ew := cipher.EncryptFrames(cw)
+	_, err = io.WriteString(ew, "hello world")
+	assert.NoError(t, err)
+	assert.NoError(t, ew.Close())
+
+	// Compatible with the existing JSON ciphertexts
+	_, enc, err := Read(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	var ref bytes.Buffer
+	assert.NoError(t, WriteJSON(&ref, enc))
+	assert.Equal(t, ref.String(), buf.String())
+
+	dr, err := cipher.NewDecryptReader(&buf)
+	assert.NoError(t, err)
+	dec, err := ioutil.ReadAll(dr)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(dec))
+}
+
+func TestContainer_EncryptWriter_Unrepresentable(t *testing.T) {
+	ew, err := cipher.NewEncryptWriter(ioutil.Discard)
+	assert.NoError(t, err)
+
+	_, err = ew.Write(make([]byte, ChunkSize+10))
+	assert.NoError(t, err)
+	_, err = ew.Write([]byte{0xff})
+	assert.NoError(t, err)
+
+	// Offset is reported relative to the whole stream
+	err = ew.Close()
+	var ubErr *UnrepresentableByteError
+	assert.True(t, errors.As(err, &ubErr))
+	assert.Equal(t, ChunkSize+10, ubErr.Offset)
+}
+
+func TestContainer_DecryptReader_WrongKey(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, cipher.Header(), testData1MByteEnc[:1024]))
+
+	// Same dimension, different data
+	i := image.Mock()
+	for !image.CheckAccept(i) {
+		i = image.Mock()
+	}
+	wrong, err := New(i)
+	assert.NoError(t, err)
+
+	_, err = wrong.NewDecryptReader(&buf)
+	assert.True(t, errors.Is(err, ErrWrongKey))
+}
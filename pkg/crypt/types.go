@@ -1,6 +1,9 @@
 package crypt
 
 import (
+	"io"
+	"sync"
+
 	"github.com/xvzf/htw-crypto-project/pkg/image"
 )
 
@@ -17,7 +20,38 @@ type PixelGroups map[uint8][]PixelPosition
 type Container struct {
 	Image       *image.Image
 	PixelGroups PixelGroups
+	// Mask selects the pixel bits representing a plaintext symbol
+	Mask uint8
+	// Fingerprint identifies the key image
+	Fingerprint []byte
+	// Lenient skips unrepresentable bytes instead of failing
+	Lenient bool
+	// Authenticate enables authenticated ciphertexts
+	Authenticate bool
+	// Flattening is the expected plaintext distribution used for limiting the pixel groups
+	Flattening *Distribution
+	// Nonce enables a per-message nonce permuting the pixel coordinates
+	Nonce bool
+	// Chaining makes the symbol of every byte depend on the previously emitted position
+	Chaining bool
+	// Exhaustion defines whether pixels may be reused within a message
+	Exhaustion Exhaustion
+	// Ledger records the pixels consumed across messages, nil if disabled
+	Ledger *Ledger
+	// Rand is the random source for homophone selection, nonces and flattening
+	Rand io.Reader
+
+	// homophones contains the pixel groups used for encryption
+	homophones PixelGroups
+	authKey    []byte
+	permKey    []byte
+	// chainOffsets are derived on first use of the chaining mode
+	chainOffsets []uint8
+	chainOnce    sync.Once
 }
 
+// Option configures a Container
+type Option func(*Container)
+
 // Encrypted contains a slice of PixelPositions
 type Encrypted []PixelPosition
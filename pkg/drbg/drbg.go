@@ -0,0 +1,42 @@
+// Package drbg implements a seeded deterministic random bit generator for reproducible
+// tests and experiments. Anybody knowing the seed can predict its output, it must never be
+// used for protecting real data.
+package drbg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"sync"
+)
+
+// Reader is an endless deterministic random stream: the AES-256-CTR keystream keyed by the
+// SHA-256 hash of the seed. It is safe for concurrent use, the output is only reproducible
+// if it is read in the same order.
+type Reader struct {
+	mu     sync.Mutex
+	stream cipher.Stream
+}
+
+// New returns the random stream of a seed
+func New(seed []byte) *Reader {
+	key := sha256.Sum256(seed)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		// A SHA-256 hash is always a valid AES-256 key
+		panic(err)
+	}
+	return &Reader{stream: cipher.NewCTR(block, make([]byte, aes.BlockSize))}
+}
+
+// Read fills p with random bytes, it never fails
+func (r *Reader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range p {
+		p[i] = 0
+	}
+	r.stream.XORKeyStream(p, p)
+	return len(p), nil
+}
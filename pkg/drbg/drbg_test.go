@@ -0,0 +1,31 @@
+package drbg
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func read(t *testing.T, r io.Reader, n int) []byte {
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	assert.NoError(t, err)
+	return buf
+}
+
+func TestReader(t *testing.T) {
+	a, b := New([]byte("seed")), New([]byte("seed"))
+
+	// The same seed yields the same stream regardless of the read sizes
+	assert.Equal(t, read(t, a, 100), append(read(t, b, 30), read(t, b, 70)...))
+	assert.Equal(t, read(t, a, 10), read(t, b, 10))
+
+	// Different seeds yield different streams
+	assert.NotEqual(t, read(t, New([]byte("seed")), 32), read(t, New([]byte("other")), 32))
+
+	// The stream is no constant
+	assert.NotEqual(t, make([]byte, 32), read(t, New(nil), 32))
+	assert.False(t, bytes.Equal(read(t, a, 16), read(t, a, 16)))
+}
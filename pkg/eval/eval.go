@@ -0,0 +1,389 @@
+// Package eval measures how well the analyzer attacks break the cipher depending on the
+// amount of ciphertext available
+package eval
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/xvzf/htw-crypto-project/pkg/crypt"
+	analyze "github.com/xvzf/htw-crypto-project/pkg/crypt/analyze"
+	"github.com/xvzf/htw-crypto-project/pkg/drbg"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+	"github.com/xvzf/htw-crypto-project/pkg/lm"
+)
+
+// Attack names an analyzer attack
+type Attack string
+
+const (
+	// AttackFrequency clusters the positions of all ciphertexts by frequency and assigns
+	// the groups to symbols by frequency rank
+	AttackFrequency Attack = "frequency"
+	// AttackLink links aligned ciphertexts of the same plaintext and assigns the groups to
+	// symbols by frequency rank
+	AttackLink Attack = "link"
+	// AttackSolve solves the first ciphertext using the language model, linked groups are
+	// used for multiple ciphertexts
+	AttackSolve Attack = "solve"
+	// AttackKnownPlaintext recovers the key from the plaintext/ciphertext pairs
+	AttackKnownPlaintext Attack = "known-plaintext"
+)
+
+// Attacks contains all supported attacks
+var Attacks = []Attack{AttackFrequency, AttackLink, AttackSolve, AttackKnownPlaintext}
+
+// ParseAttack returns the attack by its name
+func ParseAttack(name string) (Attack, error) {
+	for _, a := range Attacks {
+		if string(a) == name {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown attack %q", name)
+}
+
+// Config describes a parameter sweep, every attack is run for every combination of
+// plaintext length and repetitions
+type Config struct {
+	// Dimension of the generated keys
+	Dimension image.Dimension
+	// Lengths of the plaintexts in symbols
+	Lengths []int
+	// Repetitions is the number of encryptions of the same plaintext
+	Repetitions []int
+	// Trials per parameter combination, each with a new key and plaintext
+	Trials  int
+	Attacks []Attack
+	// Clusterer used by AttackFrequency
+	Clusterer analyze.Clusterer
+	// Model scores plaintexts and provides the symbol frequencies
+	Model *lm.Model
+	// Corpus the plaintexts are taken from, normalized to the model alphabet
+	Corpus []byte
+	// Seed selects the plaintexts, keys and homophones and seeds the solver
+	Seed int64
+	// Solver parameters, defaults are used if nil
+	Solver *analyze.Solver
+}
+
+// Result contains the metrics of a single attack run. Accuracy is the fraction of correctly
+// decrypted symbols of the first ciphertext; precision and recall compare the recovered
+// groups pairwise with the homophone groups of the used positions; key recovery is the
+// fraction of key pixels whose symbol was recovered correctly.
+type Result struct {
+	Attack         Attack  `json:"attack"`
+	Length         int     `json:"length"`
+	Repetitions    int     `json:"repetitions"`
+	Trial          int     `json:"trial"`
+	SymbolAccuracy float64 `json:"symbol_accuracy"`
+	GroupPrecision float64 `json:"group_precision"`
+	GroupRecall    float64 `json:"group_recall"`
+	KeyRecovery    float64 `json:"key_recovery"`
+	Seconds        float64 `json:"seconds"`
+}
+
+// Run executes the sweep and passes every result to emit as soon as it is available
+func Run(c *Config, emit func(Result) error) error {
+	if c.Model == nil {
+		return fmt.Errorf("a language model is required")
+	}
+	if c.Clusterer == nil {
+		c.Clusterer = analyze.StdevWalker{}
+	}
+	rnd := rand.New(rand.NewSource(c.Seed))
+
+	for _, length := range c.Lengths {
+		if length < 4 || length > len(c.Corpus) {
+			return fmt.Errorf("plaintext length %d is not between 4 and the corpus size %d", length, len(c.Corpus))
+		}
+		for _, reps := range c.Repetitions {
+			if reps < 1 {
+				return fmt.Errorf("at least one repetition is required")
+			}
+			for trial := 0; trial < c.Trials; trial++ {
+				t, err := newTrial(c, rnd, length, reps)
+				if err != nil {
+					return err
+				}
+				for _, a := range c.Attacks {
+					start := time.Now()
+					m, err := t.attack(a)
+					if err != nil {
+						return fmt.Errorf("%s: %w", a, err)
+					}
+
+					r := t.score(m)
+					r.Attack, r.Length, r.Repetitions, r.Trial = a, length, reps, trial
+					r.Seconds = time.Since(start).Seconds()
+					if err := emit(r); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// trial is a generated key together with encryptions of a plaintext
+type trial struct {
+	config *Config
+	seed   int64
+	key    *crypt.Container
+	plain  []byte
+	enc    [][]crypt.PixelPosition
+}
+
+func newTrial(c *Config, rnd *rand.Rand, length, reps int) (*trial, error) {
+	offset := rnd.Intn(len(c.Corpus) - length + 1)
+	t := &trial{
+		config: c,
+		seed:   rnd.Int63(),
+		plain:  c.Corpus[offset : offset+length],
+	}
+
+	// Keys and encryptions are derived from the seed as well, a run can be replayed exactly
+	seed := make([]byte, 8)
+	binary.BigEndian.PutUint64(seed, uint64(rnd.Int63()))
+	r := drbg.New(seed)
+
+	img, err := image.Generate(c.Dimension, image.GenerateOptions{Mask: image.MaskASCII, Rand: r})
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypt.New(img, crypt.WithRand(r))
+	if err != nil {
+		return nil, err
+	}
+	t.key = key
+
+	for i := 0; i < reps; i++ {
+		enc, err := key.Encrypt(string(t.plain))
+		if err != nil {
+			return nil, err
+		}
+		t.enc = append(t.enc, enc)
+	}
+
+	return t, nil
+}
+
+// mapping is the outcome of an attack: recovered groups and their plaintext symbols
+type mapping struct {
+	group  map[crypt.PixelPosition]int
+	symbol map[crypt.PixelPosition]byte
+}
+
+func (t *trial) attack(a Attack) (*mapping, error) {
+	switch a {
+	case AttackFrequency:
+		var all []crypt.PixelPosition
+		for _, enc := range t.enc {
+			all = append(all, enc...)
+		}
+		groups, err := analyze.Load(all).ExtractGroupsWith(t.config.Clusterer, len(t.config.Model.Alphabet()))
+		if err != nil {
+			return nil, err
+		}
+		return t.rank(groups), nil
+
+	case AttackLink:
+		return t.rank(analyze.LinkAlignedCiphertexts(t.enc).Groups), nil
+
+	case AttackSolve:
+		s := analyze.NewSolver(t.config.Model)
+		if t.config.Solver != nil {
+			copied := *t.config.Solver
+			s = &copied
+			s.Model = t.config.Model
+		}
+		s.Seed = t.seed
+
+		var groups []analyze.PixelGroupFrequency
+		if len(t.enc) > 1 {
+			groups = analyze.LinkAlignedCiphertexts(t.enc).Groups
+		}
+		sol, err := s.SolveGroups(t.enc[0], groups)
+		if err != nil {
+			return nil, err
+		}
+		return bySymbol(sol.Key), nil
+
+	case AttackKnownPlaintext:
+		var pairs []analyze.Pair
+		for _, enc := range t.enc {
+			pairs = append(pairs, analyze.Pair{Plaintext: t.plain, Ciphertext: enc, Header: t.key.Header()})
+		}
+		r, err := analyze.RecoverKey(pairs)
+		if err != nil {
+			return nil, err
+		}
+		symbols := make(map[crypt.PixelPosition]byte)
+		for i, known := range r.Known.Data {
+			if known != 0 {
+				p := crypt.PixelPosition{Width: i % r.Dimension.Width, Height: i / r.Dimension.Width}
+				symbols[p] = r.Image.Data[i]
+			}
+		}
+		return bySymbol(symbols), nil
+	}
+
+	return nil, fmt.Errorf("unknown attack %q", a)
+}
+
+// rank assigns groups to symbols by frequency rank: the most frequent group is assigned the
+// most likely symbol of the model, surplus groups the least likely one
+func (t *trial) rank(groups []analyze.PixelGroupFrequency) *mapping {
+	m := t.config.Model
+	alphabet := m.Alphabet()
+
+	symbols := make([]int, len(alphabet))
+	for i := range symbols {
+		symbols[i] = i
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return m.LogProb(symbols[i]) > m.LogProb(symbols[j])
+	})
+
+	ranked := append([]analyze.PixelGroupFrequency{}, groups...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Total > ranked[j].Total
+	})
+
+	out := &mapping{
+		group:  make(map[crypt.PixelPosition]int),
+		symbol: make(map[crypt.PixelPosition]byte),
+	}
+	for g, v := range ranked {
+		s := symbols[len(symbols)-1]
+		if g < len(symbols) {
+			s = symbols[g]
+		}
+		for _, p := range v.PixelPositions {
+			out.group[p] = g
+			out.symbol[p] = alphabet[s]
+		}
+	}
+	return out
+}
+
+// bySymbol groups positions by their recovered symbol
+func bySymbol(symbols map[crypt.PixelPosition]byte) *mapping {
+	out := &mapping{
+		group:  make(map[crypt.PixelPosition]int, len(symbols)),
+		symbol: symbols,
+	}
+	for p, s := range symbols {
+		out.group[p] = int(s)
+	}
+	return out
+}
+
+// symbol returns the symbol a key pixel represents
+func (t *trial) symbol(p crypt.PixelPosition) byte {
+	i := t.key.Image
+	return i.Data[p.Width+i.Dimension.Width*p.Height] & t.key.Mask
+}
+
+// score compares the outcome of an attack with the key
+func (t *trial) score(m *mapping) Result {
+	var r Result
+
+	correct := 0
+	for i, p := range t.enc[0] {
+		if s, ok := m.symbol[p]; ok && s == t.plain[i] {
+			correct++
+		}
+	}
+	r.SymbolAccuracy = float64(correct) / float64(len(t.plain))
+
+	recovered := 0
+	for p, s := range m.symbol {
+		if s == t.symbol(p) {
+			recovered++
+		}
+	}
+	dim := t.key.Image.Dimension
+	r.KeyRecovery = float64(recovered) / float64(dim.Width*dim.Height)
+
+	// Pairwise precision and recall using the contingency table of the used positions
+	used := make(map[crypt.PixelPosition]bool)
+	for _, enc := range t.enc {
+		for _, p := range enc {
+			used[p] = true
+		}
+	}
+	type cell struct {
+		group  int
+		symbol byte
+	}
+	cells := make(map[cell]int)
+	predicted := make(map[int]int)
+	actual := make(map[byte]int)
+	unassigned := 0
+	for p := range used {
+		g, ok := m.group[p]
+		if !ok {
+			// Unassigned positions form their own group
+			unassigned++
+			g = -unassigned
+		}
+		s := t.symbol(p)
+		cells[cell{g, s}]++
+		predicted[g]++
+		actual[s]++
+	}
+
+	var both, pairsPredicted, pairsActual float64
+	for _, n := range cells {
+		both += pairs(n)
+	}
+	for _, n := range predicted {
+		pairsPredicted += pairs(n)
+	}
+	for _, n := range actual {
+		pairsActual += pairs(n)
+	}
+	r.GroupPrecision = ratio(both, pairsPredicted)
+	r.GroupRecall = ratio(both, pairsActual)
+
+	return r
+}
+
+// pairs returns the number of pairs within a set of size n
+func pairs(n int) float64 {
+	return float64(n) * float64(n-1) / 2
+}
+
+// ratio returns a/b, 1 if there are no pairs at all
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 1
+	}
+	return a / b
+}
+
+// CSVHeader contains the column names matching Result.CSV
+var CSVHeader = []string{"attack", "length", "repetitions", "trial", "symbol_accuracy", "group_precision", "group_recall", "key_recovery", "seconds"}
+
+// CSV returns the result as CSV record
+func (r Result) CSV() []string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	return []string{
+		string(r.Attack),
+		strconv.Itoa(r.Length),
+		strconv.Itoa(r.Repetitions),
+		strconv.Itoa(r.Trial),
+		f(r.SymbolAccuracy),
+		f(r.GroupPrecision),
+		f(r.GroupRecall),
+		f(r.KeyRecovery),
+		f(r.Seconds),
+	}
+}
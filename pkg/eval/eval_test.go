@@ -0,0 +1,110 @@
+package eval
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	analyze "github.com/xvzf/htw-crypto-project/pkg/crypt/analyze"
+	"github.com/xvzf/htw-crypto-project/pkg/image"
+	"github.com/xvzf/htw-crypto-project/pkg/lm"
+)
+
+const corpus = `The Gold-Bug. MANY years ago, I contracted an intimacy with a Mr. William Legrand.
+He was of an ancient Huguenot family, and had once been wealthy; but a series of misfortunes
+had reduced him to want. To avoid the mortification consequent upon his disasters, he left
+New Orleans, the city of his forefathers, and took up his residence at Sullivan's Island,
+near Charleston, South Carolina. This Island is a very singular one. It consists of little
+else than the sea sand, and is about three miles long. Its breadth at no point exceeds a
+quarter of a mile. It is separated from the main land by a scarcely perceptible creek,
+oozing its way through a wilderness of reeds and slime, a favorite resort of the marsh hen.`
+
+func config(t *testing.T) *Config {
+	m, err := lm.Train(strings.NewReader(corpus), lm.Normalizations["words"])
+	assert.NoError(t, err)
+
+	s := analyze.NewSolver(m)
+	s.Restarts, s.Iterations = 2, 200
+
+	return &Config{
+		Dimension:   image.Dimension{Width: 32, Height: 32},
+		Lengths:     []int{200, 400},
+		Repetitions: []int{1, 20},
+		Trials:      1,
+		Attacks:     Attacks,
+		Model:       m,
+		Corpus:      m.Normalize([]byte(corpus)),
+		Solver:      s,
+	}
+}
+
+func TestRun(t *testing.T) {
+	var results []Result
+	err := Run(config(t), func(r Result) error {
+		results = append(results, r)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2*2*len(Attacks))
+
+	for _, r := range results {
+		for _, v := range []float64{r.SymbolAccuracy, r.GroupPrecision, r.GroupRecall, r.KeyRecovery} {
+			assert.GreaterOrEqual(t, v, 0.0)
+			assert.LessOrEqual(t, v, 1.0)
+		}
+		assert.Len(t, r.CSV(), len(CSVHeader))
+
+		switch r.Attack {
+		case AttackKnownPlaintext:
+			assert.Equal(t, 1.0, r.SymbolAccuracy)
+			assert.Equal(t, 1.0, r.GroupPrecision)
+			assert.Equal(t, 1.0, r.GroupRecall)
+			assert.Greater(t, r.KeyRecovery, 0.0)
+		case AttackLink:
+			// Linked positions always share the symbol
+			assert.Equal(t, 1.0, r.GroupPrecision)
+			if r.Repetitions > 1 {
+				assert.Greater(t, r.GroupRecall, 0.9)
+			}
+		}
+	}
+}
+
+func TestRun_Reproducible(t *testing.T) {
+	run := func() []Result {
+		c := config(t)
+		c.Attacks = []Attack{AttackFrequency, AttackKnownPlaintext}
+		var results []Result
+		assert.NoError(t, Run(c, func(r Result) error {
+			r.Seconds = 0
+			results = append(results, r)
+			return nil
+		}))
+		return results
+	}
+
+	// Keys, plaintexts and encryptions are derived from the seed
+	assert.Equal(t, run(), run())
+}
+
+func TestRun_Invalid(t *testing.T) {
+	emit := func(Result) error { return nil }
+
+	c := config(t)
+	c.Lengths = []int{len(c.Corpus) + 1}
+	assert.Error(t, Run(c, emit))
+
+	c = config(t)
+	c.Repetitions = []int{0}
+	assert.Error(t, Run(c, emit))
+
+	c = config(t)
+	c.Model = nil
+	assert.Error(t, Run(c, emit))
+
+	_, err := ParseAttack("brute-force")
+	assert.Error(t, err)
+	a, err := ParseAttack("link")
+	assert.NoError(t, err)
+	assert.Equal(t, AttackLink, a)
+}
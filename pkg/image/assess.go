@@ -0,0 +1,123 @@
+package image
+
+import (
+	"math"
+)
+
+// Grade summarizes the suitability of a key image
+type Grade string
+
+const (
+	// GradeGood every symbol has enough homophones
+	GradeGood Grade = "good"
+	// GradeWarning some symbols have few homophones or the group sizes are skewed
+	GradeWarning Grade = "warning"
+	// GradeCritical some symbols are represented by a single pixel only
+	GradeCritical Grade = "critical"
+	// GradeUnusable some symbols can not be represented at all
+	GradeUnusable Grade = "unusable"
+)
+
+// DefaultMinHomophones is the group size below which a symbol is considered weak
+const DefaultMinHomophones = 16
+
+// SymbolGroup contains the number of pixels (homophones) representing a symbol
+type SymbolGroup struct {
+	Symbol uint8 `json:"symbol"`
+	Pixels int   `json:"pixels"`
+}
+
+// Assessment describes the quality of a key image
+type Assessment struct {
+	Dimension Dimension `json:"dimension"`
+	Mask      uint8     `json:"mask"`
+	// Symbols is the size of the alphabet selected by the mask
+	Symbols int `json:"symbols"`
+	// Groups contains the group size of every symbol, ordered by symbol
+	Groups []SymbolGroup `json:"groups"`
+
+	Min  int     `json:"min"`
+	Max  int     `json:"max"`
+	Mean float64 `json:"mean"`
+	// Entropy of the symbol distribution across the pixels in bits, at most log2(Symbols)
+	Entropy    float64 `json:"entropy"`
+	MaxEntropy float64 `json:"max_entropy"`
+
+	// Missing symbols can not be encrypted
+	Missing []int `json:"missing"`
+	// Weak symbols have less than MinHomophones pixels
+	Weak          []SymbolGroup `json:"weak"`
+	MinHomophones int           `json:"min_homophones"`
+
+	Grade Grade `json:"grade"`
+}
+
+// Assess reports the homophone group sizes of every symbol selected by mask. Symbols with
+// less than minHomophones pixels are reported as weak, as their pixels are reused often
+// and leak the symbol frequency.
+func Assess(i *Image, mask uint8, minHomophones int) *Assessment {
+	if minHomophones < 1 {
+		minHomophones = DefaultMinHomophones
+	}
+
+	counts := make(map[uint8]int)
+	for _, b := range i.Data {
+		counts[b&mask]++
+	}
+
+	a := &Assessment{
+		Dimension:     i.Dimension,
+		Mask:          mask,
+		MinHomophones: minHomophones,
+		Min:           math.MaxInt32,
+		Missing:       []int{},
+		Weak:          []SymbolGroup{},
+	}
+
+	total := len(i.Data)
+	for v := 0; v < 256; v++ {
+		s := uint8(v)
+		if s&mask != s {
+			continue
+		}
+		a.Symbols++
+
+		g := SymbolGroup{Symbol: s, Pixels: counts[s]}
+		a.Groups = append(a.Groups, g)
+
+		if g.Pixels < a.Min {
+			a.Min = g.Pixels
+		}
+		if g.Pixels > a.Max {
+			a.Max = g.Pixels
+		}
+
+		switch {
+		case g.Pixels == 0:
+			a.Missing = append(a.Missing, v)
+		case g.Pixels < minHomophones:
+			a.Weak = append(a.Weak, g)
+		}
+
+		if g.Pixels > 0 {
+			p := float64(g.Pixels) / float64(total)
+			a.Entropy -= p * math.Log2(p)
+		}
+	}
+
+	a.Mean = float64(total) / float64(a.Symbols)
+	a.MaxEntropy = math.Log2(float64(a.Symbols))
+
+	switch {
+	case len(a.Missing) > 0:
+		a.Grade = GradeUnusable
+	case a.Min < 2:
+		a.Grade = GradeCritical
+	case len(a.Weak) > 0 || a.Entropy < 0.9*a.MaxEntropy:
+		a.Grade = GradeWarning
+	default:
+		a.Grade = GradeGood
+	}
+
+	return a
+}
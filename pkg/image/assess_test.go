@@ -0,0 +1,55 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssess(t *testing.T) {
+	i, err := Generate(Dimension{Width: 128, Height: 128}, GenerateOptions{Balance: true})
+	assert.NoError(t, err)
+
+	a := Assess(i, MaskASCII, 0)
+	assert.Equal(t, 128, a.Symbols)
+	assert.Len(t, a.Groups, 128)
+	assert.Equal(t, 128, a.Min)
+	assert.Equal(t, 128, a.Max)
+	assert.Equal(t, 128.0, a.Mean)
+	assert.InDelta(t, 7.0, a.Entropy, 1e-9)
+	assert.Empty(t, a.Missing)
+	assert.Empty(t, a.Weak)
+	assert.Equal(t, GradeGood, a.Grade)
+
+	// Only a few pixels per symbol
+	a = Assess(i, MaskASCII, 200)
+	assert.Len(t, a.Weak, 128)
+	assert.Equal(t, GradeWarning, a.Grade)
+
+	// Most symbols missing
+	i.Data[0], i.Data[1] = 5, 5
+	for n := range i.Data[2:] {
+		i.Data[n+2] = 6
+	}
+	a = Assess(i, MaskASCII, 0)
+	assert.Equal(t, GradeUnusable, a.Grade)
+	assert.Len(t, a.Missing, 126)
+}
+
+func TestAssess_Critical(t *testing.T) {
+	i, err := Generate(Dimension{Width: 16, Height: 16}, GenerateOptions{Mask: MaskASCII, Balance: true})
+	assert.NoError(t, err)
+
+	// Every symbol has two pixels, drop one of symbol 'a'
+	for n, v := range i.Data {
+		if v&MaskASCII == 'a' {
+			i.Data[n] = 'b'
+			break
+		}
+	}
+
+	a := Assess(i, MaskASCII, 0)
+	assert.Equal(t, 1, a.Min)
+	assert.Equal(t, 3, a.Max)
+	assert.Equal(t, GradeCritical, a.Grade)
+}
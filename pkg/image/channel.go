@@ -0,0 +1,136 @@
+package image
+
+import (
+	"fmt"
+	gi "image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// Channel selects how the pixel colors of a PNG are converted into key values
+type Channel uint8
+
+const (
+	// ChannelDefault uses the low byte of the red channel, the grey value of 8-bit greyscale images
+	ChannelDefault Channel = iota
+	// ChannelRed uses the 8-bit red channel
+	ChannelRed
+	// ChannelGreen uses the 8-bit green channel
+	ChannelGreen
+	// ChannelBlue uses the 8-bit blue channel
+	ChannelBlue
+	// ChannelAlpha uses the 8-bit alpha channel
+	ChannelAlpha
+	// ChannelLuminance converts the color to an 8-bit grey value
+	ChannelLuminance
+	// ChannelAll uses red, green and blue as separate key cells, tripling the width of color
+	// images; greyscale images use their grey value
+	ChannelAll
+)
+
+var channelNames = []string{"default", "red", "green", "blue", "alpha", "luminance", "all"}
+
+func (c Channel) String() string {
+	if int(c) < len(channelNames) {
+		return channelNames[c]
+	}
+	return fmt.Sprintf("channel(%d)", uint8(c))
+}
+
+// ParseChannel parses the name of a channel
+func ParseChannel(s string) (Channel, error) {
+	for c, name := range channelNames {
+		if name == s {
+			return Channel(c), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown channel %q", s)
+}
+
+// ReadChannel supports loading greyscale (8/16-bit), color and paletted PNG files, the
+// channel selects how pixel colors are converted into key values
+func ReadChannel(r io.Reader, ch Channel) (*Image, error) {
+	if ch > ChannelAll {
+		return nil, fmt.Errorf("unknown channel %d", ch)
+	}
+
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+
+	_, grey := greyValue(img, b.Min.X, b.Min.Y)
+
+	cells := 1
+	if ch == ChannelAll && !grey {
+		cells = 3
+	}
+
+	i := &Image{
+		Data:      make([]uint8, cells*b.Dx()*b.Dy()),
+		Dimension: Dimension{Width: cells * b.Dx(), Height: b.Dy()},
+		Channel:   ch,
+	}
+
+	for h := 0; h < b.Dy(); h++ {
+		for w := 0; w < b.Dx(); w++ {
+			x, y := b.Min.X+w, b.Min.Y+h
+			pos := cells*w + i.Dimension.Width*h
+
+			if ch == ChannelDefault {
+				pixelValue, _, _, _ := img.At(x, y).RGBA()
+				i.Data[pos] = uint8(pixelValue)
+				continue
+			}
+
+			if v, ok := greyValue(img, x, y); ok && ch != ChannelAlpha {
+				i.Data[pos] = v
+				continue
+			}
+
+			c := nrgba(img, x, y)
+			switch ch {
+			case ChannelRed:
+				i.Data[pos] = c.R
+			case ChannelGreen:
+				i.Data[pos] = c.G
+			case ChannelBlue:
+				i.Data[pos] = c.B
+			case ChannelAlpha:
+				i.Data[pos] = c.A
+			case ChannelLuminance:
+				i.Data[pos] = uint8((299*uint32(c.R) + 587*uint32(c.G) + 114*uint32(c.B) + 500) / 1000)
+			case ChannelAll:
+				i.Data[pos], i.Data[pos+1], i.Data[pos+2] = c.R, c.G, c.B
+			}
+		}
+	}
+
+	return i, nil
+}
+
+// greyValue returns the 8-bit grey value of greyscale images
+func greyValue(img gi.Image, x, y int) (uint8, bool) {
+	switch img := img.(type) {
+	case *gi.Gray:
+		return img.GrayAt(x, y).Y, true
+	case *gi.Gray16:
+		return uint8(img.Gray16At(x, y).Y >> 8), true
+	}
+	return 0, false
+}
+
+// nrgba returns the non-premultiplied 8-bit color of a pixel
+func nrgba(img gi.Image, x, y int) color.NRGBA {
+	switch img := img.(type) {
+	case *gi.NRGBA:
+		return img.NRGBAAt(x, y)
+	case *gi.RGBA:
+		return color.NRGBAModel.Convert(img.RGBAAt(x, y)).(color.NRGBA)
+	case *gi.Paletted:
+		return color.NRGBAModel.Convert(img.Palette[img.ColorIndexAt(x, y)]).(color.NRGBA)
+	}
+	return color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+}
@@ -0,0 +1,85 @@
+package image
+
+import (
+	"bytes"
+	gi "image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encode(t *testing.T, img gi.Image) *bytes.Reader {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestParseChannel(t *testing.T) {
+	for c := ChannelDefault; c <= ChannelAll; c++ {
+		p, err := ParseChannel(c.String())
+		assert.NoError(t, err)
+		assert.Equal(t, c, p)
+	}
+	_, err := ParseChannel("purple")
+	assert.Error(t, err)
+}
+
+func TestReadChannel_Gray16(t *testing.T) {
+	img := gi.NewGray16(gi.Rect(0, 0, 2, 1))
+	img.SetGray16(0, 0, color.Gray16{Y: 0x1234})
+	img.SetGray16(1, 0, color.Gray16{Y: 0xabcd})
+
+	// Legacy behaviour uses the low byte
+	i, err := ReadChannel(encode(t, img), ChannelDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0x34, 0xcd}, i.Data)
+
+	for _, ch := range []Channel{ChannelRed, ChannelLuminance, ChannelAll} {
+		i, err := ReadChannel(encode(t, img), ch)
+		assert.NoError(t, err)
+		assert.Equal(t, []uint8{0x12, 0xab}, i.Data)
+		assert.Equal(t, Dimension{Width: 2, Height: 1}, i.Dimension)
+		assert.Equal(t, ch, i.Channel)
+	}
+}
+
+func TestReadChannel_Color(t *testing.T) {
+	rgba := gi.NewRGBA(gi.Rect(0, 0, 2, 1))
+	rgba.SetRGBA(0, 0, color.RGBA{R: 10, G: 20, B: 30, A: 255})
+	rgba.SetRGBA(1, 0, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+
+	nrgba := gi.NewNRGBA(gi.Rect(0, 0, 2, 1))
+	nrgba.SetNRGBA(0, 0, color.NRGBA{R: 10, G: 20, B: 30, A: 128})
+	nrgba.SetNRGBA(1, 0, color.NRGBA{R: 200, G: 100, B: 50, A: 64})
+
+	paletted := gi.NewPaletted(gi.Rect(0, 0, 2, 1), color.Palette{
+		color.RGBA{R: 200, G: 100, B: 50, A: 255},
+		color.RGBA{R: 10, G: 20, B: 30, A: 255},
+	})
+	paletted.SetColorIndex(0, 0, 1)
+	paletted.SetColorIndex(1, 0, 0)
+
+	for _, img := range []gi.Image{rgba, nrgba, paletted} {
+		for ch, expected := range map[Channel][]uint8{
+			ChannelRed:       {10, 200},
+			ChannelGreen:     {20, 100},
+			ChannelBlue:      {30, 50},
+			ChannelLuminance: {18, 124},
+			ChannelAll:       {10, 20, 30, 200, 100, 50},
+		} {
+			i, err := ReadChannel(encode(t, img), ch)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, i.Data, "%T %s", img, ch)
+		}
+	}
+
+	i, err := ReadChannel(encode(t, nrgba), ChannelAlpha)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{128, 64}, i.Data)
+
+	i, err = ReadChannel(encode(t, rgba), ChannelAll)
+	assert.NoError(t, err)
+	assert.Equal(t, Dimension{Width: 6, Height: 1}, i.Dimension)
+}
@@ -0,0 +1,93 @@
+package image
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xvzf/htw-crypto-project/pkg/random"
+)
+
+// GenerateOptions configures the key image generation
+type GenerateOptions struct {
+	// Mask selects the alphabet which has to be represented, defaults to MaskASCII
+	Mask uint8
+	// MinPixels is the minimum number of pixels representing every symbol, defaults to 1
+	MinPixels int
+	// Balance distributes the pixels evenly across all symbols instead of randomly
+	Balance bool
+	// Rand is the random source, defaults to crypto/rand
+	Rand io.Reader
+}
+
+// Generate creates a random key image using a CSPRNG. Every symbol selected by the mask
+// is guaranteed to be represented by at least MinPixels pixels, the bits not selected by
+// the mask are random.
+func Generate(dim Dimension, opts GenerateOptions) (*Image, error) {
+	if opts.Mask == 0 {
+		opts.Mask = MaskASCII
+	}
+	if opts.MinPixels < 1 {
+		opts.MinPixels = 1
+	}
+	if dim.Width < 1 || dim.Height < 1 {
+		return nil, errors.New("invalid image dimension")
+	}
+
+	// Symbols representable with the mask
+	var symbols []uint8
+	for v := 0; v < 256; v++ {
+		if uint8(v)&opts.Mask == uint8(v) {
+			symbols = append(symbols, uint8(v))
+		}
+	}
+
+	size := dim.Width * dim.Height
+	if size < opts.MinPixels*len(symbols) {
+		return nil, fmt.Errorf("%dx%d pixels can not represent %d symbols with %d pixels each",
+			dim.Width, dim.Height, len(symbols), opts.MinPixels)
+	}
+
+	if opts.Rand == nil {
+		opts.Rand = rand.Reader
+	}
+	rnd := random.NewSampler(opts.Rand)
+
+	i := &Image{
+		Data:      make([]uint8, size),
+		Dimension: dim,
+	}
+
+	// Guaranteed pixels first, the remaining ones either round robin or random
+	for n := range i.Data {
+		if n < opts.MinPixels*len(symbols) || opts.Balance {
+			i.Data[n] = symbols[n%len(symbols)]
+			continue
+		}
+		s, err := rnd.Intn(len(symbols))
+		if err != nil {
+			return nil, err
+		}
+		i.Data[n] = symbols[s]
+	}
+
+	// Shuffle the pixels (Fisher-Yates) & randomize the bits not covered by the mask
+	for n := len(i.Data) - 1; n >= 0; n-- {
+		j, err := rnd.Intn(n + 1)
+		if err != nil {
+			return nil, err
+		}
+		i.Data[n], i.Data[j] = i.Data[j], i.Data[n]
+
+		if opts.Mask != MaskByte {
+			b, err := rnd.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			i.Data[n] |= b &^ opts.Mask
+		}
+	}
+
+	return i, nil
+}
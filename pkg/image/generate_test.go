@@ -0,0 +1,47 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func count(i *Image, mask uint8) map[uint8]int {
+	c := make(map[uint8]int)
+	for _, v := range i.Data {
+		c[v&mask]++
+	}
+	return c
+}
+
+func TestGenerate(t *testing.T) {
+	i, err := Generate(Dimension{Width: 64, Height: 48}, GenerateOptions{MinPixels: 20})
+	assert.NoError(t, err)
+	assert.Len(t, i.Data, 64*48)
+	assert.True(t, CheckAccept(i))
+
+	for s := 0; s < 128; s++ {
+		assert.GreaterOrEqual(t, count(i, MaskASCII)[uint8(s)], 20)
+	}
+}
+
+func TestGenerate_Balance(t *testing.T) {
+	i, err := Generate(Dimension{Width: 100, Height: 100}, GenerateOptions{Mask: MaskByte, Balance: true})
+	assert.NoError(t, err)
+	assert.True(t, CheckAcceptMask(i, MaskByte))
+
+	c := count(i, MaskByte)
+	assert.Len(t, c, 256)
+	for _, v := range c {
+		// 10000 pixels / 256 symbols
+		assert.True(t, v == 39 || v == 40)
+	}
+}
+
+func TestGenerate_TooSmall(t *testing.T) {
+	_, err := Generate(Dimension{Width: 10, Height: 10}, GenerateOptions{})
+	assert.Error(t, err)
+
+	_, err = Generate(Dimension{Width: 16, Height: 16}, GenerateOptions{MinPixels: 3})
+	assert.Error(t, err)
+}
@@ -2,9 +2,12 @@
 package image
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	gi "image"
 	"image/color"
 	"image/png"
+	"io"
 	"math/rand"
 	"os"
 	"sync"
@@ -12,8 +15,8 @@ import (
 
 // Dimension contains infos about the image dimension
 type Dimension struct {
-	Width  int
-	Height int
+	Width  int `json:"width"`
+	Height int `json:"height"`
 }
 
 // Image contains the datastructure representing a greyscale image
@@ -23,32 +26,13 @@ type Image struct {
 	Data []uint8 // addressing: Data[width + Dimension.Width * height]
 	// Dimension contains the image dimensions
 	Dimension Dimension
+	// Channel used for converting the pixel colors when reading the image
+	Channel Channel
 }
 
 // Read supports loading a PNG file (greyscale)
-func Read(f *os.File) (*Image, error) {
-	img, err := png.Decode(f)
-	if err != nil {
-		return nil, err
-	}
-	b := img.Bounds()
-
-	// Generate mock image
-	i := &Image{
-		Data:      make([]uint8, b.Dx()*b.Dy(), b.Dx()*b.Dy()),
-		Dimension: Dimension{Width: b.Dx(), Height: b.Dy()},
-	}
-
-	// Fill mock image with mock data
-	for h := 0; h < i.Dimension.Height; h++ {
-		for w := 0; w < i.Dimension.Width; w++ {
-			c := img.At(w, h)
-			pixelValue, _, _, _ := c.RGBA()
-			i.Data[w+i.Dimension.Width*h] = uint8(uint8(pixelValue))
-		}
-	}
-
-	return i, nil
+func Read(r io.Reader) (*Image, error) {
+	return ReadChannel(r, ChannelDefault)
 }
 
 // Write supports writing a PNG file (greyscale)
@@ -65,12 +49,52 @@ func Write(f *os.File, i *Image) error {
 		}
 	}
 
-	png.Encode(f, img)
-	return nil
+	return png.Encode(f, img)
+}
+
+// Masks selecting the bits of a pixel value which carry the plaintext symbol
+const (
+	// MaskASCII eliminates the most significant bit, mapping pixels onto 7-bit ASCII
+	MaskASCII uint8 = 0b01111111
+	// MaskByte keeps all bits, mapping pixels onto the full 8-bit byte range
+	MaskByte uint8 = 0b11111111
+)
+
+// FingerprintSize is the length of a key image fingerprint in bytes
+const FingerprintSize = 16
+
+// Fingerprint returns a truncated hash identifying the image by its dimension and data
+func Fingerprint(i *Image) []byte {
+	return Hash(i, "htw-crypto-project key fingerprint")[:FingerprintSize]
 }
 
-// Mock creates an image with 128x128 dimension for testing purposes
+// Hash returns the SHA-256 hash of the image dimension and data, separated by a domain string.
+// It allows deriving independent secrets from the key image.
+func Hash(i *Image, domain string) []byte {
+	h := sha256.New()
+	h.Write([]byte(domain))
+
+	dim := make([]byte, 8)
+	binary.BigEndian.PutUint32(dim[:4], uint32(i.Dimension.Width))
+	binary.BigEndian.PutUint32(dim[4:], uint32(i.Dimension.Height))
+	h.Write(dim)
+	h.Write(i.Data)
+
+	return h.Sum(nil)
+}
+
+// mockRand makes the sequence of mock images reproducible
+var (
+	mockRand   = rand.New(rand.NewSource(1))
+	mockRandMu sync.Mutex
+)
+
+// Mock creates an image with 128x128 dimension for testing purposes. Mock images are taken
+// from a fixed seeded sequence, a test run always sees the same images.
 func Mock() *Image {
+	mockRandMu.Lock()
+	defer mockRandMu.Unlock()
+
 	// Generate mock image
 	i := &Image{
 		Data:      make([]uint8, 128*128, 128*128),
@@ -80,7 +104,7 @@ func Mock() *Image {
 	// Fill mock image with mock data
 	for h := 0; h < i.Dimension.Height; h++ {
 		for w := 0; w < i.Dimension.Width; w++ {
-			i.Data[w+i.Dimension.Width*h] = uint8(rand.Intn(128))
+			i.Data[w+i.Dimension.Width*h] = uint8(mockRand.Intn(256))
 		}
 	}
 
@@ -89,13 +113,18 @@ func Mock() *Image {
 
 // CheckAccept check if we can map all characters on the image -> determine if it is suited or not.
 func CheckAccept(i *Image) bool {
+	return CheckAcceptMask(i, MaskASCII)
+}
+
+// CheckAcceptMask checks if every symbol selected by mask (0..mask) can be mapped on the image.
+func CheckAcceptMask(i *Image, mask uint8) bool {
 	acceptanceMap := make(map[uint8]bool)
 	for _, b := range i.Data {
-		acceptanceMap[b&0b01111111] = true
+		acceptanceMap[b&mask] = true
 	}
 
-	// Check if ASCII alphabet can be represented
-	for c := 0; c < 128; c++ {
+	// Check if the alphabet can be represented
+	for c := 0; c <= int(mask); c++ {
 		if _, ok := acceptanceMap[uint8(c)]; !ok {
 			return false
 		}
@@ -13,6 +13,18 @@ func TestMockAcceptance(t *testing.T) {
 	assert.True(t, CheckAccept(i))
 }
 
+func TestCheckAcceptMask(t *testing.T) {
+	i := Mock()
+	assert.True(t, CheckAcceptMask(i, MaskByte))
+
+	// Only 7-bit values -> not suited for the byte-clean mode
+	for n := range i.Data {
+		i.Data[n] &= MaskASCII
+	}
+	assert.True(t, CheckAcceptMask(i, MaskASCII))
+	assert.False(t, CheckAcceptMask(i, MaskByte))
+}
+
 // Test Image Writing capabilities
 func TestWrite(t *testing.T) {
 	i := Mock()
@@ -40,3 +52,19 @@ func TestRead(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, i, n)
 }
+
+func TestFingerprint(t *testing.T) {
+	i := Mock()
+	f := Fingerprint(i)
+	assert.Len(t, f, FingerprintSize)
+	assert.Equal(t, f, Fingerprint(i))
+
+	// Same data, different dimension
+	r := &Image{Data: i.Data, Dimension: Dimension{Width: 256, Height: 64}}
+	assert.NotEqual(t, f, Fingerprint(r))
+
+	// Different data
+	n := &Image{Data: append([]uint8{}, i.Data...), Dimension: i.Dimension}
+	n.Data[42]++
+	assert.NotEqual(t, f, Fingerprint(n))
+}
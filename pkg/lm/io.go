@@ -0,0 +1,157 @@
+package lm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrInvalidFormat is returned when loading a file which is not a model
+var ErrInvalidFormat = errors.New("Invalid language model format")
+
+// Binary format:
+//
+//	magic "HTWL" | version | flags | uvarint alphabet length | alphabet
+//	per order: uvarint n-gram count | uvarint seen n-grams | seen n-grams
+//
+// Only seen n-grams are stored as uvarint index delta followed by the negated log10
+// probability in 1/quantization steps as uint16; unseen n-grams are derived from the count.
+var magic = []byte("HTWL")
+
+const (
+	version      = 1
+	quantization = 1000
+
+	flagFold  = 1 << 0
+	flagSpace = 1 << 1
+)
+
+// Save writes the model in a compact binary format, probabilities are rounded to 1/1000 log10
+func (m *Model) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	var flags byte
+	if m.Normalization.Fold {
+		flags |= flagFold
+	}
+	if m.Normalization.Space {
+		flags |= flagSpace
+	}
+
+	bw.Write(magic)
+	bw.WriteByte(version)
+	bw.WriteByte(flags)
+	writeUvarint(bw, uint64(len(m.Normalization.Alphabet)))
+	bw.WriteString(m.Normalization.Alphabet)
+
+	for o, t := range m.tables {
+		floor := float32(math.Log10(0.01 / float64(m.Counts[o])))
+
+		// Entries are written to a buffer first as the number of seen n-grams is unknown
+		var entries bytes.Buffer
+		seen, last := 0, 0
+		for i, p := range t {
+			if p == floor {
+				continue
+			}
+			writeUvarint(&entries, uint64(i-last))
+			binary.Write(&entries, binary.BigEndian, quantize(p))
+			seen++
+			last = i
+		}
+
+		writeUvarint(bw, uint64(m.Counts[o]))
+		writeUvarint(bw, uint64(seen))
+		if _, err := entries.WriteTo(bw); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Load reads a model written by Save
+func Load(r io.Reader) (*Model, error) {
+	br := bufio.NewReader(r)
+
+	head := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(br, head); err != nil || !bytes.Equal(head[:len(magic)], magic) {
+		return nil, ErrInvalidFormat
+	}
+	if head[len(magic)] != version {
+		return nil, ErrInvalidFormat
+	}
+	flags := head[len(magic)+1]
+
+	size, err := binary.ReadUvarint(br)
+	if err != nil || size > MaxAlphabet {
+		return nil, ErrInvalidFormat
+	}
+	alphabet := make([]byte, size)
+	if _, err := io.ReadFull(br, alphabet); err != nil {
+		return nil, ErrInvalidFormat
+	}
+
+	n := Normalization{
+		Alphabet: string(alphabet),
+		Fold:     flags&flagFold != 0,
+		Space:    flags&flagSpace != 0,
+	}
+	if err := n.Validate(); err != nil {
+		return nil, ErrInvalidFormat
+	}
+
+	m := newModel(n)
+	for o, t := range m.tables {
+		count, err := binary.ReadUvarint(br)
+		if err != nil || count == 0 {
+			return nil, ErrInvalidFormat
+		}
+		m.Counts[o] = int(count)
+
+		floor := float32(math.Log10(0.01 / float64(count)))
+		for i := range t {
+			t[i] = floor
+		}
+
+		seen, err := binary.ReadUvarint(br)
+		if err != nil || seen > uint64(len(t)) {
+			return nil, ErrInvalidFormat
+		}
+		i := 0
+		for s := uint64(0); s < seen; s++ {
+			delta, err := binary.ReadUvarint(br)
+			if err != nil {
+				return nil, ErrInvalidFormat
+			}
+			var q uint16
+			if err := binary.Read(br, binary.BigEndian, &q); err != nil {
+				return nil, ErrInvalidFormat
+			}
+			i += int(delta)
+			if delta > uint64(len(t)) || i >= len(t) {
+				return nil, ErrInvalidFormat
+			}
+			t[i] = -float32(q) / quantization
+		}
+	}
+
+	return m, nil
+}
+
+// quantize converts a log10 probability to its stored representation
+func quantize(p float32) uint16 {
+	q := math.Round(-float64(p) * quantization)
+	if q > math.MaxUint16 {
+		q = math.MaxUint16
+	}
+	return uint16(q)
+}
+
+func writeUvarint(w io.Writer, v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.Write(buf[:binary.PutUvarint(buf, v)])
+}
@@ -0,0 +1,48 @@
+package lm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_SaveLoad(t *testing.T) {
+	m, err := Train(strings.NewReader(corpus), Normalizations["words"])
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, m.Save(&buf))
+
+	// Only seen n-grams are stored
+	assert.Less(t, buf.Len(), len(m.tables[Order-1])/100)
+
+	l, err := Load(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, m.Normalization, l.Normalization)
+	assert.Equal(t, m.Counts, l.Counts)
+	for o := range m.tables {
+		assert.Len(t, l.tables[o], len(m.tables[o]))
+		for i := range m.tables[o] {
+			assert.InDelta(t, m.tables[o][i], l.tables[o][i], 0.0005)
+		}
+	}
+	assert.Equal(t, m.Normalize([]byte(corpus)), l.Normalize([]byte(corpus)))
+
+	// Saving a loaded model is stable
+	var again bytes.Buffer
+	assert.NoError(t, l.Save(&again))
+	assert.Equal(t, buf.Bytes(), again.Bytes())
+
+	// Invalid or truncated files
+	for _, in := range [][]byte{
+		nil,
+		[]byte("HTWC\x01\x00"),
+		buf.Bytes()[:buf.Len()-1],
+		buf.Bytes()[:10],
+	} {
+		_, err := Load(bytes.NewReader(in))
+		assert.Equal(t, ErrInvalidFormat, err)
+	}
+}
@@ -0,0 +1,159 @@
+// Package lm provides n-gram language models used for scoring candidate plaintexts
+package lm
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+)
+
+// Order is the longest n-gram of a model
+const Order = 4
+
+// MaxAlphabet is the maximum alphabet size, limiting the quadgram table to 1M entries
+const MaxAlphabet = 32
+
+// Model contains the log10 probabilities of all n-grams up to Order
+type Model struct {
+	Normalization Normalization
+	// Counts contains the number of n-grams seen in the corpus per order
+	Counts [Order]int
+
+	nz *normalizer
+	// tables contains the probabilities per order, addressed by the n-gram symbol indices
+	// in base len(Alphabet)
+	tables [Order][]float32
+}
+
+// Train builds a model from a corpus normalized to the alphabet. Unseen n-grams are assigned
+// a probability below any seen one.
+func Train(corpus io.Reader, n Normalization) (*Model, error) {
+	if err := n.Validate(); err != nil {
+		return nil, err
+	}
+
+	m := newModel(n)
+	counts := make([][]int, Order)
+	for o := range counts {
+		counts[o] = make([]int, len(m.tables[o]))
+	}
+
+	var window [Order]int
+	seen := 0
+	r := bufio.NewReader(corpus)
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		c, ok := m.nz.next(b)
+		if !ok {
+			continue
+		}
+		copy(window[:], window[1:])
+		window[Order-1] = c
+		seen++
+
+		// Count every n-gram ending at the current symbol
+		for o := 0; o < Order && o < seen; o++ {
+			counts[o][m.index(window[Order-1-o:])]++
+			m.Counts[o]++
+		}
+	}
+	if m.Counts[Order-1] == 0 {
+		return nil, errors.New("corpus is too short for training")
+	}
+
+	for o, c := range counts {
+		floor := math.Log10(0.01 / float64(m.Counts[o]))
+		for i, v := range c {
+			if v > 0 {
+				m.tables[o][i] = float32(math.Log10(float64(v) / float64(m.Counts[o])))
+			} else {
+				m.tables[o][i] = float32(floor)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func newModel(n Normalization) *Model {
+	m := &Model{
+		Normalization: n,
+		nz:            newNormalizer(n),
+	}
+	size := 1
+	for o := range m.tables {
+		size *= len(n.Alphabet)
+		m.tables[o] = make([]float32, size)
+	}
+	return m
+}
+
+// index returns the table index of an n-gram
+func (m *Model) index(gram []int) int {
+	out := 0
+	for _, c := range gram {
+		out = out*len(m.Normalization.Alphabet) + c
+	}
+	return out
+}
+
+// Alphabet returns the symbols of the model
+func (m *Model) Alphabet() string {
+	return m.Normalization.Alphabet
+}
+
+// Symbol returns the alphabet index of a symbol, -1 if it is not part of the alphabet
+func (m *Model) Symbol(b byte) int {
+	return m.nz.index[b]
+}
+
+// Normalize converts a text to the alphabet of the model
+func (m *Model) Normalize(in []byte) []byte {
+	nz := newNormalizer(m.Normalization)
+	out := make([]byte, 0, len(in))
+	for _, b := range in {
+		if c, ok := nz.next(b); ok {
+			out = append(out, m.Normalization.Alphabet[c])
+		}
+	}
+	return out
+}
+
+// Encode converts a normalized text to alphabet indices, symbols outside the alphabet are dropped
+func (m *Model) Encode(in []byte) []int {
+	out := make([]int, 0, len(in))
+	for _, b := range in {
+		if c := m.nz.index[b]; c >= 0 {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// LogProb returns the log10 probability of an n-gram of alphabet indices, 1 <= len(gram) <= Order
+func (m *Model) LogProb(gram ...int) float64 {
+	return float64(m.tables[len(gram)-1][m.index(gram)])
+}
+
+// Quadgram returns the log10 probability of a quadgram of alphabet indices
+func (m *Model) Quadgram(a, b, c, d int) float64 {
+	n := len(m.Normalization.Alphabet)
+	return float64(m.tables[3][((a*n+b)*n+c)*n+d])
+}
+
+// Score returns the sum of the log10 probabilities of all n-grams of a text of alphabet indices
+func (m *Model) Score(text []int, n int) float64 {
+	var out float64
+	for i := 0; i+n <= len(text); i++ {
+		out += float64(m.tables[n-1][m.index(text[i:i+n])])
+	}
+	return out
+}
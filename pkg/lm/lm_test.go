@@ -0,0 +1,53 @@
+package lm
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const corpus = `It was the best of times, it was the worst of times, it was the age of wisdom,
+it was the age of foolishness, it was the epoch of belief, it was the epoch of incredulity,
+it was the season of Light, it was the season of Darkness, it was the spring of hope,
+it was the winter of despair.`
+
+func TestTrain(t *testing.T) {
+	m, err := Train(strings.NewReader(corpus), Normalizations["words"])
+	assert.NoError(t, err)
+	assert.Equal(t, Letters+" ", m.Alphabet())
+
+	text := m.Encode(m.Normalize([]byte(corpus)))
+	for o := 1; o <= Order; o++ {
+		assert.Equal(t, len(text)-o+1, m.Counts[o-1])
+	}
+
+	// Probabilities of every order sum up to 1 over the seen n-grams
+	for o := 0; o < Order; o++ {
+		var sum float64
+		seen := make(map[int]bool)
+		for i := 0; i+o < len(text); i++ {
+			idx := m.index(text[i : i+o+1])
+			if !seen[idx] {
+				seen[idx] = true
+				sum += math.Pow(10, m.LogProb(text[i:i+o+1]...))
+			}
+		}
+		assert.InDelta(t, 1.0, sum, 1e-4)
+	}
+
+	// Unseen n-grams are less likely than seen ones
+	the := m.Encode([]byte("THE "))
+	qzx := m.Encode([]byte("QZXJ"))
+	assert.Greater(t, m.Quadgram(the[0], the[1], the[2], the[3]), m.Quadgram(qzx[0], qzx[1], qzx[2], qzx[3]))
+	assert.Equal(t, m.LogProb(the...), m.Quadgram(the[0], the[1], the[2], the[3]))
+	assert.Greater(t, m.Score(m.Encode([]byte("IT WAS THE")), 4), m.Score(m.Encode([]byte("EHT SAW TI")), 4))
+	assert.Equal(t, -1, m.Symbol('a'))
+	assert.Equal(t, 0, m.Symbol('A'))
+
+	_, err = Train(strings.NewReader("abc"), Normalizations["letters"])
+	assert.Error(t, err)
+	_, err = Train(strings.NewReader(corpus), Normalization{Alphabet: "AB", Space: true})
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package lm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Letters contains the upper case latin alphabet
+const Letters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Normalization describes how a text is mapped onto the alphabet of a model
+type Normalization struct {
+	// Alphabet contains the symbols of the model, at most MaxAlphabet
+	Alphabet string
+	// Fold converts lower case letters to upper case
+	Fold bool
+	// Space collapses whitespace sequences to a single space, which has to be part of the alphabet
+	Space bool
+}
+
+// Normalizations supported by ParseNormalization
+var Normalizations = map[string]Normalization{
+	// letters matches crack/assets/transform.py: upper case letters only
+	"letters": {Alphabet: Letters, Fold: true},
+	// words keeps the word boundaries transform.py intends to keep
+	"words": {Alphabet: Letters + " ", Fold: true, Space: true},
+}
+
+// ParseNormalization returns a predefined normalization by its name
+func ParseNormalization(name string) (Normalization, error) {
+	n, ok := Normalizations[strings.ToLower(name)]
+	if !ok {
+		return n, fmt.Errorf("unknown normalization %q", name)
+	}
+	return n, nil
+}
+
+// Validate checks the alphabet is usable
+func (n Normalization) Validate() error {
+	if len(n.Alphabet) < 2 || len(n.Alphabet) > MaxAlphabet {
+		return fmt.Errorf("alphabet has to contain between 2 and %d symbols", MaxAlphabet)
+	}
+	seen := make(map[byte]bool)
+	for i := 0; i < len(n.Alphabet); i++ {
+		if seen[n.Alphabet[i]] {
+			return fmt.Errorf("symbol %q is part of the alphabet multiple times", n.Alphabet[i])
+		}
+		seen[n.Alphabet[i]] = true
+	}
+	if n.Space && !seen[' '] {
+		return fmt.Errorf("alphabet has to contain a space for collapsing whitespace")
+	}
+	return nil
+}
+
+// normalizer maps bytes to alphabet indices
+type normalizer struct {
+	Normalization
+	index [256]int
+	// space is set if the previous symbol was whitespace
+	space bool
+}
+
+func newNormalizer(n Normalization) *normalizer {
+	nz := &normalizer{Normalization: n}
+	for i := range nz.index {
+		nz.index[i] = -1
+	}
+	for i := 0; i < len(n.Alphabet); i++ {
+		nz.index[n.Alphabet[i]] = i
+	}
+	return nz
+}
+
+// next returns the alphabet index of a byte, false if it is dropped
+func (nz *normalizer) next(b byte) (int, bool) {
+	if nz.Fold && b >= 'a' && b <= 'z' {
+		b -= 'a' - 'A'
+	}
+	if nz.Space && (b == ' ' || b == '\n' || b == '\r' || b == '\t') {
+		if nz.space {
+			return 0, false
+		}
+		nz.space = true
+		return nz.index[' '], true
+	}
+
+	c := nz.index[b]
+	if c < 0 {
+		return 0, false
+	}
+	nz.space = false
+	return c, true
+}
@@ -0,0 +1,34 @@
+package lm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalization(t *testing.T) {
+	in := []byte("It was the  best\nof times,\t1859!")
+
+	for name, want := range map[string]string{
+		"letters": "ITWASTHEBESTOFTIMES",
+		"words":   "IT WAS THE BEST OF TIMES ",
+	} {
+		n, err := ParseNormalization(name)
+		assert.NoError(t, err)
+		m, err := Train(strings.NewReader(corpus), n)
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(m.Normalize(in)), name)
+	}
+
+	// Case sensitive alphabets keep lower case letters
+	m, err := Train(strings.NewReader(corpus), Normalization{Alphabet: "abcdefghijklmnopqrstuvwxyz"})
+	assert.NoError(t, err)
+	assert.Equal(t, "twasthebestoftimes", string(m.Normalize(in)))
+
+	_, err = ParseNormalization("unknown")
+	assert.Error(t, err)
+	assert.Error(t, Normalization{Alphabet: "AA"}.Validate())
+	assert.Error(t, Normalization{Alphabet: "A"}.Validate())
+	assert.NoError(t, Normalizations["words"].Validate())
+}
@@ -0,0 +1,47 @@
+// Package random draws unbiased random numbers from a random byte stream
+package random
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// bufferSize is the number of random bytes fetched from the source at once
+const bufferSize = 4096
+
+// Sampler draws unbiased random numbers from a buffered random source. It is not safe for
+// concurrent use.
+type Sampler struct {
+	r   *bufio.Reader
+	buf [4]byte
+}
+
+// NewSampler returns a sampler reading from r, e.g. crypto/rand.Reader
+func NewSampler(r io.Reader) *Sampler {
+	return &Sampler{r: bufio.NewReaderSize(r, bufferSize)}
+}
+
+// Intn returns a uniformly distributed number in [0, n). Values of the upper, incomplete
+// multiple of n are rejected, which avoids the modulo bias.
+func (s *Sampler) Intn(n int) (int, error) {
+	if n <= 0 || uint64(n) > 1<<32 {
+		return 0, errors.New("invalid sample range")
+	}
+
+	limit := (1 << 32) - (1<<32)%uint64(n)
+	for {
+		if _, err := io.ReadFull(s.r, s.buf[:]); err != nil {
+			return 0, err
+		}
+		if v := uint64(binary.BigEndian.Uint32(s.buf[:])); v < limit {
+			return int(v % uint64(n)), nil
+		}
+	}
+}
+
+// ReadByte returns a random byte
+func (s *Sampler) ReadByte() (byte, error) {
+	return s.r.ReadByte()
+}
@@ -0,0 +1,48 @@
+package random
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// errReader fails every read
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestSampler(t *testing.T) {
+	s := NewSampler(rand.Reader)
+
+	counts := make([]int, 3)
+	for i := 0; i < 3000; i++ {
+		v, err := s.Intn(3)
+		assert.NoError(t, err)
+		counts[v]++
+	}
+	for _, n := range counts {
+		assert.InDelta(t, 1000, n, 150)
+	}
+
+	_, err := s.Intn(0)
+	assert.Error(t, err)
+
+	// Values of the incomplete upper range are rejected
+	s = NewSampler(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 5, 7}))
+	v, err := s.Intn(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+	b, err := s.ReadByte()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(7), b)
+
+	// Errors of the random source are returned
+	failing := errors.New("no entropy")
+	_, err = NewSampler(errReader{failing}).Intn(3)
+	assert.Equal(t, failing, err)
+}